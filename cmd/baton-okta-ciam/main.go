@@ -64,11 +64,28 @@ func getConnector(ctx context.Context, oc *config.OktaCiam) (types.ConnectorServ
 	ccfg := &connector.Config{
 		Domain:              oc.Domain,
 		ApiToken:            oc.ApiToken,
+		OktaClientId:        oc.OktaClientId,
+		OktaPrivateKey:      oc.OktaPrivateKey,
+		OktaPrivateKeyId:    oc.OktaPrivateKeyId,
+		OktaScopes:          oc.OktaScopes,
 		CiamEmailDomains:    oc.CiamEmailDomains,
 		Cache:               oc.Cache,
 		CacheTTI:            cacheTTI,
 		CacheTTL:            cacheTTL,
 		SkipSecondaryEmails: oc.SkipSecondaryEmails,
+		SyncInactiveApps:    oc.SyncInactiveApps,
+		AppGroupPriority:    int64(oc.AppGroupPriority),
+		SyncCustomRoles:     oc.SyncCustomRoles,
+
+		UseAdministratorsEndpoint: oc.UseAdministratorsEndpoint,
+		AccountTypeRules:          oc.AccountTypeRules,
+		SyncIdentityProviders:     oc.SyncIdentityProviders,
+		SyncAuthenticators:        oc.SyncAuthenticators,
+		DeactivateOnlyOnDelete:    oc.DeactivateOnlyOnDelete,
+		NoPasswordRotation:        oc.NoPasswordRotation,
+		EventFilterGroups:         oc.EventFilterGroups,
+		ExtraEventTypes:           oc.ExtraEventTypes,
+		AppUsageLookbackDays:      int64(oc.AppUsageLookbackDays),
 	}
 
 	cb, err := connector.New(ctx, ccfg)
@@ -6,11 +6,27 @@ import "reflect"
 type OktaCiam struct {
 	Domain string `mapstructure:"domain"`
 	ApiToken string `mapstructure:"api-token"`
+	OktaClientId string `mapstructure:"okta-client-id"`
+	OktaPrivateKey string `mapstructure:"okta-private-key"`
+	OktaPrivateKeyId string `mapstructure:"okta-private-key-id"`
+	OktaScopes []string `mapstructure:"okta-scopes"`
 	CiamEmailDomains []string `mapstructure:"ciam-email-domains"`
 	Cache bool `mapstructure:"cache"`
 	CacheTti int `mapstructure:"cache-tti"`
 	CacheTtl int `mapstructure:"cache-ttl"`
 	SkipSecondaryEmails bool `mapstructure:"skip-secondary-emails"`
+	SyncInactiveApps bool `mapstructure:"sync-inactive-apps"`
+	AppGroupPriority int `mapstructure:"app-group-priority"`
+	SyncCustomRoles bool `mapstructure:"sync-custom-roles"`
+	UseAdministratorsEndpoint bool `mapstructure:"use-administrators-endpoint"`
+	AccountTypeRules []string `mapstructure:"account-type-rules"`
+	SyncIdentityProviders bool `mapstructure:"sync-identity-providers"`
+	SyncAuthenticators bool `mapstructure:"sync-authenticators"`
+	DeactivateOnlyOnDelete bool `mapstructure:"deactivate-only-on-delete"`
+	NoPasswordRotation string `mapstructure:"no-password-rotation"`
+	EventFilterGroups []string `mapstructure:"event-filter-groups"`
+	ExtraEventTypes []string `mapstructure:"extra-event-types"`
+	AppUsageLookbackDays int `mapstructure:"app-usage-lookback-days"`
 }
 
 func (c* OktaCiam) findFieldByTag(tagValue string) (any, bool) {
@@ -15,9 +15,30 @@ var (
 		"api-token",
 		field.WithDisplayName("API token"),
 		field.WithDescription("The API token for the service account"),
-		field.WithRequired(true),
 		field.WithIsSecret(true),
 	)
+	oktaClientId = field.StringField(
+		"okta-client-id",
+		field.WithDisplayName("Client ID"),
+		field.WithDescription("The client ID of the Okta API service app used for OAuth 2.0 private key JWT authentication"),
+	)
+	oktaPrivateKey = field.StringField(
+		"okta-private-key",
+		field.WithDisplayName("Private key"),
+		field.WithDescription("The PEM encoded private key (or a path to it) registered on the Okta API service app"),
+		field.WithIsSecret(true),
+	)
+	oktaPrivateKeyId = field.StringField(
+		"okta-private-key-id",
+		field.WithDisplayName("Private key ID"),
+		field.WithDescription("The key ID (kid) of the private key registered on the Okta API service app"),
+	)
+	oktaScopes = field.StringSliceField(
+		"okta-scopes",
+		field.WithDisplayName("OAuth scopes"),
+		field.WithDescription("The OAuth scopes to request when authenticating with a private key"),
+		field.WithDefaultValue(DefaultOAuthScopes),
+	)
 	ciamEmailDomains = field.StringSliceField(
 		"ciam-email-domains",
 		field.WithDisplayName("Okta email domains (optional)"),
@@ -28,19 +49,109 @@ var (
 	cacheTTI            = field.IntField("cache-tti", field.WithDescription("Response cache cleanup interval in seconds"), field.WithDefaultValue(60))
 	cacheTTL            = field.IntField("cache-ttl", field.WithDescription("Response cache time to live in seconds"), field.WithDefaultValue(300))
 	skipSecondaryEmails = field.BoolField("skip-secondary-emails", field.WithDescription("Skip syncing secondary emails"), field.WithDefaultValue(false))
+	syncInactiveApps    = field.BoolField("sync-inactive-apps", field.WithDescription("Whether to sync inactive apps or not"), field.WithDefaultValue(true))
+	syncCustomRoles     = field.BoolField("sync-custom-roles", field.WithDescription("Enable syncing custom roles, resource sets and resource set bindings"), field.WithDefaultValue(false))
+	appGroupPriority    = field.IntField(
+		"app-group-priority",
+		field.WithDescription("The priority given to new app group assignments. A negative value lets Okta assign the lowest priority"),
+		field.WithDefaultValue(-1),
+	)
+	useAdministratorsEndpoint = field.BoolField(
+		"use-administrators-endpoint",
+		field.WithDescription("Discover admin role assignments with Okta's internal administrators endpoint instead of the IAM assignees API"),
+		field.WithDefaultValue(false),
+	)
+	syncIdentityProviders = field.BoolField(
+		"sync-identity-providers",
+		field.WithDescription("Enable syncing identity providers and the users linked to them. Also records the credential provider type of every user. Requires the okta.idps.read scope when using a private key"),
+		field.WithDefaultValue(false),
+	)
+	syncAuthenticators = field.BoolField(
+		"sync-authenticators",
+		field.WithDescription("Enable syncing authenticators and the factors users have enrolled in them. Requires the okta.authenticators.read scope when using a private key. Admin users are always checked for a phishing-resistant factor, even when this is disabled"),
+		field.WithDefaultValue(false),
+	)
+	deactivateOnlyOnDelete = field.BoolField(
+		"deactivate-only-on-delete",
+		field.WithDescription("Only deactivate users when deleting them, instead of permanently deleting them from Okta"),
+		field.WithDefaultValue(false),
+	)
+	noPasswordRotation = field.StringField(
+		"no-password-rotation",
+		field.WithDescription("How users are rotated to no password: reset-email emails them a password reset link, expire expires their password so it has to be changed on the next sign in"),
+		field.WithDefaultValue("reset-email"),
+	)
+	eventFilterGroups = field.StringSliceField(
+		"event-filter-groups",
+		field.WithDescription("The groups of System Log events in the event feeds: lifecycle, privilege, group-membership, security, authentication and app-usage"),
+		field.WithDefaultValue([]string{"lifecycle", "privilege", "group-membership", "security"}),
+	)
+	extraEventTypes = field.StringSliceField(
+		"extra-event-types",
+		field.WithDescription("Additional System Log event types reported in the event feed as changes to their target user"),
+	)
+	appUsageLookbackDays = field.IntField(
+		"app-usage-lookback-days",
+		field.WithDescription("The number of days of System Log sign ins used to record when each user last used their directly assigned apps during sync. The latest 10,000 sign ins of every app are read once per sync, which counts against the System Log rate limit. 0 disables it"),
+		field.WithDefaultValue(0),
+	)
+	accountTypeRules = field.StringSliceField(
+		"account-type-rules",
+		field.WithDescription("Rules setting the account type of users, as <attribute>=<value>:<human|service|system>. The attribute is either type for the user type name, or a profile attribute. The first matching rule wins"),
+	)
 )
 
-var relationships = []field.SchemaFieldRelationship{}
+// DefaultOAuthScopes are the scopes requested when using private key authentication and no scopes are configured.
+var DefaultOAuthScopes = []string{
+	"okta.users.read",
+	"okta.users.manage",
+	"okta.userTypes.read",
+	"okta.schemas.read",
+	"okta.groups.read",
+	"okta.groups.manage",
+	"okta.apps.read",
+	"okta.apps.manage",
+	"okta.idps.read",
+	"okta.roles.read",
+	"okta.roles.manage",
+	"okta.orgs.read",
+	"okta.logs.read",
+	"okta.policies.read",
+}
+
+var relationships = []field.SchemaFieldRelationship{
+	field.FieldsAtLeastOneUsed(apiToken, oktaClientId),
+	field.FieldsMutuallyExclusive(apiToken, oktaClientId),
+	field.FieldsMutuallyExclusive(apiToken, oktaPrivateKey),
+	field.FieldsMutuallyExclusive(apiToken, oktaPrivateKeyId),
+	field.FieldsRequiredTogether(oktaClientId, oktaPrivateKey, oktaPrivateKeyId),
+}
 
 //go:generate go run ./gen
 var Config = field.NewConfiguration([]field.SchemaField{
 	domain,
 	apiToken,
+	oktaClientId,
+	oktaPrivateKey,
+	oktaPrivateKeyId,
+	oktaScopes,
 	ciamEmailDomains,
 	cache,
 	cacheTTI,
 	cacheTTL,
 	skipSecondaryEmails,
+	syncInactiveApps,
+	appGroupPriority,
+	syncCustomRoles,
+	useAdministratorsEndpoint,
+	accountTypeRules,
+	syncIdentityProviders,
+	syncAuthenticators,
+	deactivateOnlyOnDelete,
+	noPasswordRotation,
+	eventFilterGroups,
+	extraEventTypes,
+	appUsageLookbackDays,
 },
 	field.WithConstraints(relationships...),
 	field.WithConnectorDisplayName("Okta CIAM"),
@@ -0,0 +1,392 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+
+	config "github.com/conductorone/baton-sdk/pb/c1/config/v1"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/actions"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/okta/okta-sdk-golang/v2/okta/query"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	actionResetFactors  = "reset_factors"
+	actionResetFactor   = "reset_factor"
+	actionUnlockUser    = "unlock_user"
+	actionClearSessions = "clear_sessions"
+
+	actionArgUserID            = "user_id"
+	actionArgFactorType        = "factor_type"
+	actionArgRevokeOAuthTokens = "revoke_oauth_tokens"
+
+	actionResultSuccess      = "success"
+	actionResultUserID       = "user_id"
+	actionResultChanged      = "changed"
+	actionResultStatus       = "status"
+	actionResultMessage      = "message"
+	actionResultFactorsReset = "factors_reset"
+)
+
+// Factor types that can be reset on their own with the reset_factor action.
+var resettableFactorTypes = []string{
+	"email",
+	"sms",
+	"call",
+	"push",
+	"signed_nonce",
+	"token:software:totp",
+	"token:hardware",
+	"token",
+	"webauthn",
+	"u2f",
+	"question",
+	"web",
+}
+
+var (
+	userIDActionArg = &config.Field{
+		Name:        actionArgUserID,
+		DisplayName: "User ID",
+		Description: "The Okta ID of the user",
+		Placeholder: "00ujp51vjgWd6ylZ6697",
+		IsRequired:  true,
+		Field:       &config.Field_StringField{StringField: &config.StringField{}},
+	}
+
+	// Every action returns these, along with its own results.
+	userActionReturnTypes = []*config.Field{
+		{Name: actionResultSuccess, DisplayName: "Success", Field: &config.Field_BoolField{BoolField: &config.BoolField{}}},
+		{Name: actionResultUserID, DisplayName: "User ID", Field: &config.Field_StringField{StringField: &config.StringField{}}},
+		{Name: actionResultChanged, DisplayName: "Changed", Description: "False when the user was already in the requested state", Field: &config.Field_BoolField{BoolField: &config.BoolField{}}},
+		{Name: actionResultStatus, DisplayName: "Status", Description: "The status of the user after the action", Field: &config.Field_StringField{StringField: &config.StringField{}}},
+		{Name: actionResultMessage, DisplayName: "Message", Field: &config.Field_StringField{StringField: &config.StringField{}}},
+	}
+
+	factorsResetReturnType = &config.Field{
+		Name:        actionResultFactorsReset,
+		DisplayName: "Factors Reset",
+		Description: "The number of factors that were reset",
+		Field:       &config.Field_IntField{IntField: &config.IntField{}},
+	}
+)
+
+func resetFactorsActionSchema() *v2.BatonActionSchema {
+	return &v2.BatonActionSchema{
+		Name:        actionResetFactors,
+		DisplayName: "Reset All Factors",
+		Description: "Resets every factor the user has enrolled, the user has to enroll again on their next sign in",
+		Arguments:   []*config.Field{userIDActionArg},
+		ReturnTypes: append(slices.Clone(userActionReturnTypes), factorsResetReturnType),
+	}
+}
+
+func resetFactorActionSchema() *v2.BatonActionSchema {
+	options := make([]*config.StringFieldOption, 0, len(resettableFactorTypes))
+	for _, factorType := range resettableFactorTypes {
+		options = append(options, &config.StringFieldOption{Name: factorType, Value: factorType})
+	}
+
+	return &v2.BatonActionSchema{
+		Name:        actionResetFactor,
+		DisplayName: "Reset Factor",
+		Description: "Resets every factor of one type the user has enrolled",
+		Arguments: []*config.Field{
+			userIDActionArg,
+			{
+				Name:        actionArgFactorType,
+				DisplayName: "Factor Type",
+				Description: "The type of factor to reset",
+				IsRequired:  true,
+				Field:       &config.Field_StringField{StringField: &config.StringField{Options: options}},
+			},
+		},
+		ReturnTypes: append(slices.Clone(userActionReturnTypes), factorsResetReturnType),
+	}
+}
+
+func unlockUserActionSchema() *v2.BatonActionSchema {
+	return &v2.BatonActionSchema{
+		Name:        actionUnlockUser,
+		DisplayName: "Unlock User",
+		Description: "Unlocks a user that is locked out after too many failed sign in attempts",
+		Arguments:   []*config.Field{userIDActionArg},
+		ReturnTypes: userActionReturnTypes,
+	}
+}
+
+func clearSessionsActionSchema() *v2.BatonActionSchema {
+	return &v2.BatonActionSchema{
+		Name:        actionClearSessions,
+		DisplayName: "Clear User Sessions",
+		Description: "Signs the user out of every Okta session",
+		Arguments: []*config.Field{
+			userIDActionArg,
+			{
+				Name:        actionArgRevokeOAuthTokens,
+				DisplayName: "Revoke OAuth Tokens",
+				Description: "Also revoke the OAuth and OpenID Connect tokens issued to the user",
+				Field:       &config.Field_BoolField{BoolField: &config.BoolField{}},
+			},
+		},
+		ReturnTypes: userActionReturnTypes,
+	}
+}
+
+// newActionManager registers the custom actions of the connector.
+func newActionManager(ctx context.Context, o *Okta) (*actions.ActionManager, error) {
+	am := actions.NewActionManager(ctx)
+
+	for _, action := range []struct {
+		schema  *v2.BatonActionSchema
+		handler actions.ActionHandler
+	}{
+		{resetFactorsActionSchema(), o.resetFactorsAction},
+		{resetFactorActionSchema(), o.resetFactorAction},
+		{unlockUserActionSchema(), o.unlockUserAction},
+		{clearSessionsActionSchema(), o.clearSessionsAction},
+	} {
+		err := am.RegisterAction(ctx, action.schema.Name, action.schema, action.handler)
+		if err != nil {
+			return nil, fmt.Errorf("okta-connector: failed to register action %s: %w", action.schema.Name, err)
+		}
+	}
+
+	for _, schema := range userLifecycleActionSchemas() {
+		err := am.RegisterAction(ctx, schema.Name, schema, o.userLifecycleActionHandler(schema.Name))
+		if err != nil {
+			return nil, fmt.Errorf("okta-connector: failed to register action %s: %w", schema.Name, err)
+		}
+	}
+
+	return am, nil
+}
+
+func (o *Okta) ListActionSchemas(ctx context.Context) ([]*v2.BatonActionSchema, annotations.Annotations, error) {
+	return o.actionManager.ListActionSchemas(ctx)
+}
+
+func (o *Okta) GetActionSchema(ctx context.Context, name string) (*v2.BatonActionSchema, annotations.Annotations, error) {
+	return o.actionManager.GetActionSchema(ctx, name)
+}
+
+func (o *Okta) InvokeAction(ctx context.Context, name string, args *structpb.Struct) (string, v2.BatonActionStatus, *structpb.Struct, annotations.Annotations, error) {
+	return o.actionManager.InvokeAction(ctx, name, args)
+}
+
+func (o *Okta) GetActionStatus(ctx context.Context, id string) (v2.BatonActionStatus, string, *structpb.Struct, annotations.Annotations, error) {
+	return o.actionManager.GetActionStatus(ctx, id)
+}
+
+func (o *Okta) resetFactorsAction(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	userID, err := requiredStringArg(args, actionArgUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	factors, err := listCurrentUserFactors(ctx, o.client, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connector: failed to list user factors: %w", err)
+	}
+
+	if len(factors) == 0 {
+		return actionResult(userID, false, "", "user has no factors enrolled", map[string]interface{}{
+			actionResultFactorsReset: 0,
+		})
+	}
+
+	response, err := o.client.User.ResetFactors(ctx, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connector: failed to reset user factors: %w", handleOktaResponseError(response, err))
+	}
+
+	l.Warn("User factors have been reset",
+		zap.String("user_id", userID),
+		zap.Int("factors_reset", len(factors)),
+	)
+
+	return actionResult(userID, true, "", "", map[string]interface{}{
+		actionResultFactorsReset: len(factors),
+	})
+}
+
+func (o *Okta) resetFactorAction(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	userID, err := requiredStringArg(args, actionArgUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	factorType, err := requiredStringArg(args, actionArgFactorType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	factors, err := listCurrentUserFactors(ctx, o.client, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connector: failed to list user factors: %w", err)
+	}
+
+	reset := 0
+	for _, factor := range factors {
+		if factor.FactorType != factorType {
+			continue
+		}
+
+		response, err := o.client.UserFactor.DeleteFactor(ctx, userID, factor.Id)
+		if err != nil {
+			// The factor was removed since it was listed.
+			if response != nil && response.StatusCode == http.StatusNotFound {
+				continue
+			}
+			return nil, nil, fmt.Errorf("okta-connector: failed to reset %s factor: %w", factorType, handleOktaResponseError(response, err))
+		}
+		reset++
+	}
+
+	if reset == 0 {
+		return actionResult(userID, false, "", fmt.Sprintf("user has no %s factor enrolled", factorType), map[string]interface{}{
+			actionResultFactorsReset: 0,
+		})
+	}
+
+	l.Warn("User factor has been reset",
+		zap.String("user_id", userID),
+		zap.String("factor_type", factorType),
+		zap.Int("factors_reset", reset),
+	)
+
+	return actionResult(userID, true, "", "", map[string]interface{}{
+		actionResultFactorsReset: reset,
+	})
+}
+
+func (o *Okta) unlockUserAction(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	userID, err := requiredStringArg(args, actionArgUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, _, err := getCurrentUser(ctx, o.client, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connector: failed to get user: %w", err)
+	}
+
+	if user.Status != userStatusLockedOut {
+		return actionResult(userID, false, user.Status, fmt.Sprintf("user is %s, not locked out", user.Status), nil)
+	}
+
+	response, err := o.client.User.UnlockUser(ctx, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connector: failed to unlock user: %w", handleOktaResponseError(response, err))
+	}
+
+	l.Warn("User has been unlocked", zap.String("user_id", userID))
+
+	return actionResult(userID, true, userStatusActive, "", nil)
+}
+
+func (o *Okta) clearSessionsAction(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	userID, err := requiredStringArg(args, actionArgUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	revokeOAuthTokens := boolArg(args, actionArgRevokeOAuthTokens)
+	response, err := o.client.User.ClearUserSessions(ctx, userID, query.NewQueryParams(query.WithOauthTokens(revokeOAuthTokens)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connector: failed to clear user sessions: %w", handleOktaResponseError(response, err))
+	}
+
+	l.Warn("User sessions have been cleared",
+		zap.String("user_id", userID),
+		zap.Bool("revoke_oauth_tokens", revokeOAuthTokens),
+	)
+
+	return actionResult(userID, true, "", "", nil)
+}
+
+// listCurrentUserFactors lists the factors of a user bypassing the response cache, so factors enrolled or reset since
+// the last read are seen.
+func listCurrentUserFactors(ctx context.Context, client *okta.Client, userID string) ([]*okta.UserFactor, error) {
+	reqUrl, err := url.JoinPath(usersUrl, userID, "factors")
+	if err != nil {
+		return nil, err
+	}
+
+	var factors []*okta.UserFactor
+	resp, err := doUncachedRequest(ctx, client, http.MethodGet, reqUrl, nil, &factors)
+	if err != nil {
+		return nil, handleOktaResponseError(resp, err)
+	}
+
+	return factors, nil
+}
+
+// getCurrentUser gets a user bypassing the response cache, so actions see the status the user has now and not the
+// one from before an earlier action.
+func getCurrentUser(ctx context.Context, client *okta.Client, userID string) (*okta.User, *okta.Response, error) {
+	reqUrl, err := url.JoinPath(usersUrl, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var user *okta.User
+	resp, err := doUncachedRequest(ctx, client, http.MethodGet, reqUrl, nil, &user)
+	if err != nil {
+		return nil, resp, handleOktaResponseError(resp, err)
+	}
+
+	return user, resp, nil
+}
+
+// requiredStringArg returns a string argument of an action, or an error if it's missing or empty.
+func requiredStringArg(args *structpb.Struct, name string) (string, error) {
+	value, ok := args.GetFields()[name].GetKind().(*structpb.Value_StringValue)
+	if !ok || value.StringValue == "" {
+		return "", fmt.Errorf("okta-connector: missing required argument %s", name)
+	}
+
+	return value.StringValue, nil
+}
+
+// boolArg returns a bool argument of an action, which is false when it's missing.
+func boolArg(args *structpb.Struct, name string) bool {
+	return args.GetFields()[name].GetBoolValue()
+}
+
+// actionResult builds the structured result of an action on a user. Changed is false when the user was already in
+// the requested state, with the message saying why nothing was done.
+func actionResult(userID string, changed bool, status string, message string, extra map[string]interface{}) (*structpb.Struct, annotations.Annotations, error) {
+	result := map[string]interface{}{
+		actionResultSuccess: true,
+		actionResultUserID:  userID,
+		actionResultChanged: changed,
+	}
+	if status != "" {
+		result[actionResultStatus] = status
+	}
+	if message != "" {
+		result[actionResultMessage] = message
+	}
+	for k, v := range extra {
+		result[k] = v
+	}
+
+	rv, err := structpb.NewStruct(result)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connector: failed to build action result: %w", err)
+	}
+
+	return rv, nil, nil
+}
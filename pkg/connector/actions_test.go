@@ -0,0 +1,97 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func Test_newActionManager(t *testing.T) {
+	ctx := context.Background()
+	am, err := newActionManager(ctx, &Okta{})
+	require.NoError(t, err)
+
+	schemas, _, err := am.ListActionSchemas(ctx)
+	require.NoError(t, err)
+
+	var names []string
+	for _, schema := range schemas {
+		names = append(names, schema.Name)
+		require.NotEmpty(t, schema.DisplayName, schema.Name)
+		require.NotEmpty(t, schema.Arguments, schema.Name)
+		require.Equal(t, actionArgUserID, schema.Arguments[0].Name, schema.Name)
+		require.True(t, schema.Arguments[0].IsRequired, schema.Name)
+	}
+	require.ElementsMatch(t, []string{
+		actionResetFactors, actionResetFactor, actionUnlockUser, actionClearSessions,
+		actionActivateUser, actionDeactivateUser, actionSuspendUser, actionUnsuspendUser, actionReactivateUser, actionExpirePassword,
+	}, names)
+}
+
+func Test_actionArgs(t *testing.T) {
+	args, err := structpb.NewStruct(map[string]interface{}{
+		"user_id":             "00u1",
+		"empty":               "",
+		"revoke_oauth_tokens": true,
+	})
+	require.NoError(t, err)
+
+	userID, err := requiredStringArg(args, actionArgUserID)
+	require.NoError(t, err)
+	require.Equal(t, "00u1", userID)
+
+	_, err = requiredStringArg(args, "empty")
+	require.Error(t, err)
+	_, err = requiredStringArg(args, "missing")
+	require.Error(t, err)
+	_, err = requiredStringArg(nil, actionArgUserID)
+	require.Error(t, err)
+
+	require.True(t, boolArg(args, actionArgRevokeOAuthTokens))
+	require.False(t, boolArg(args, "missing"))
+}
+
+func Test_actionResult(t *testing.T) {
+	rv, _, err := actionResult("00u1", false, "ACTIVE", "user is ACTIVE, not locked out", map[string]interface{}{
+		actionResultFactorsReset: 0,
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"success":       true,
+		"user_id":       "00u1",
+		"changed":       false,
+		"status":        "ACTIVE",
+		"message":       "user is ACTIVE, not locked out",
+		"factors_reset": float64(0),
+	}, rv.AsMap())
+}
+
+func Test_resetFactorsActionSeesNewFactors(t *testing.T) {
+	factors := `[]`
+	reset := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/users/00u1/factors", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(factors))
+	})
+	mux.HandleFunc("POST /api/v1/users/00u1/lifecycle/reset_factors", func(w http.ResponseWriter, r *http.Request) {
+		reset = true
+		_, _ = w.Write([]byte(`{}`))
+	})
+	o := &Okta{client: newTestClient(t, mux)}
+	ctx := context.Background()
+
+	// A sync read the factors before the user enrolled one.
+	_, err := listUserFactors(ctx, o.client, "00u1")
+	require.NoError(t, err)
+	factors = `[{"id": "opf1", "factorType": "push", "provider": "OKTA", "status": "ACTIVE"}]`
+
+	args, err := structpb.NewStruct(map[string]interface{}{actionArgUserID: "00u1"})
+	require.NoError(t, err)
+	result, _, err := o.resetFactorsAction(ctx, args)
+	require.NoError(t, err)
+	require.True(t, reset)
+	require.Equal(t, float64(1), result.Fields[actionResultFactorsReset].GetNumberValue())
+}
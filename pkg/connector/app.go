@@ -0,0 +1,614 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	sdkEntitlement "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	sdkGrant "github.com/conductorone/baton-sdk/pkg/types/grant"
+	sdkResource "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/okta/okta-sdk-golang/v2/okta/query"
+	"go.uber.org/zap"
+)
+
+const (
+	apiPathApps = "/api/v1/apps"
+
+	appStatusActive   = "ACTIVE"
+	appStatusInactive = "INACTIVE"
+
+	appAccessEntitlement = "access"
+
+	appUserScopeUser = "USER"
+)
+
+type appResourceType struct {
+	resourceType *v2.ResourceType
+	emailFilters []string
+	connector    *Okta
+	usage        appUsageCache
+}
+
+func (o *appResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return o.resourceType
+}
+
+func (o *appResourceType) List(
+	ctx context.Context,
+	resourceID *v2.ResourceId,
+	token *pagination.Token,
+) ([]*v2.Resource, string, annotations.Annotations, error) {
+	bag, page, err := parsePageToken(token.Token, &v2.ResourceId{ResourceType: resourceTypeApp.Id})
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse page token: %w", err)
+	}
+
+	qp := queryParams(token.Size, page)
+	if !o.connector.syncInactiveApps {
+		qp.Filter = filterMaker("status", appStatusActive)
+	}
+
+	apps, respCtx, err := listApps(ctx, o.connector.client, token, qp)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list apps: %w", err)
+	}
+
+	nextPage, annos, err := parseResp(respCtx.OktaResponse)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	err = bag.Next(nextPage)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to fetch bag.Next: %w", err)
+	}
+
+	var rv []*v2.Resource
+	for _, app := range apps {
+		resource, err := appResource(ctx, app)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, resource)
+	}
+
+	pageToken, err := bag.Marshal()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, pageToken, annos, nil
+}
+
+func (o *appResourceType) Entitlements(
+	_ context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	en := sdkEntitlement.NewAssignmentEntitlement(resource, appAccessEntitlement,
+		sdkEntitlement.WithDisplayName(fmt.Sprintf("%s App Access", resource.DisplayName)),
+		sdkEntitlement.WithDescription(fmt.Sprintf("Has access to the %s app in Okta", resource.DisplayName)),
+		sdkEntitlement.WithAnnotation(&v2.V1Identifier{
+			Id: V1MembershipEntitlementID(resource.Id.GetResource()),
+		}),
+		sdkEntitlement.WithGrantableTo(resourceTypeUser, resourceTypeGroup),
+	)
+
+	rv := []*v2.Entitlement{en}
+	rv = append(rv, roleTargetEntitlements(resource, appTargetRoleTypes)...)
+
+	return rv, "", nil, nil
+}
+
+func (o *appResourceType) Grants(
+	ctx context.Context,
+	resource *v2.Resource,
+	token *pagination.Token,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	bag := &pagination.Bag{}
+	err := bag.Unmarshal(token.Token)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse page token: %w", err)
+	}
+
+	if bag.Current() == nil {
+		bag.Push(pagination.PageState{
+			ResourceTypeID: resourceTypeGroup.Id,
+		})
+		bag.Push(pagination.PageState{
+			ResourceTypeID: resourceTypeUser.Id,
+		})
+	}
+
+	appID := resource.Id.GetResource()
+	qp := queryParams(token.Size, bag.PageToken())
+
+	var rv []*v2.Grant
+	var respCtx *responseContext
+
+	switch bag.ResourceTypeID() {
+	case resourceTypeUser.Id:
+		// Embed the okta user so that we can apply the same email domain filter as the user syncer.
+		qp.Expand = "user"
+		var appUsers []*okta.AppUser
+		appUsers, respCtx, err = listAppUsers(ctx, o.connector.client, appID, token, qp)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list app users: %w", err)
+		}
+
+		// Only direct assignments get the last sign in, group assignments are expanded from the group grant.
+		var lastUsed map[string]time.Time
+		if o.connector.appUsageLookback > 0 {
+			lastUsed, err = o.usage.get(ctx, o.connector.client, appID, o.connector.appUsageLookback)
+			if err != nil {
+				return nil, "", nil, err
+			}
+		}
+
+		for _, appUser := range appUsers {
+			// Assignments inherited from a group are expanded from the group grant.
+			if appUser.Scope != appUserScopeUser {
+				continue
+			}
+
+			user, err := embeddedAppUser(appUser)
+			if err != nil {
+				l.Warn("okta-connectorv2: failed to read embedded app user", zap.String("user_id", appUser.Id), zap.Error(err))
+				continue
+			}
+			if user == nil || !shouldIncludeOktaUser(user, o.emailFilters) {
+				continue
+			}
+
+			var opts []sdkGrant.GrantOption
+			if usedAt, ok := lastUsed[appUser.Id]; ok {
+				opts = append(opts, sdkGrant.WithGrantMetadata(map[string]interface{}{
+					appLastUsedMetadataKey: usedAt.Format(time.RFC3339),
+				}))
+			}
+			rv = append(rv, appUserGrant(resource, appUser, opts...))
+		}
+	case resourceTypeGroup.Id:
+		var assignments []*okta.ApplicationGroupAssignment
+		assignments, respCtx, err = listAppGroupAssignments(ctx, o.connector.client, appID, token, qp)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list app group assignments: %w", err)
+		}
+
+		for _, assignment := range assignments {
+			rv = append(rv, appGroupGrant(resource, assignment))
+		}
+	default:
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: unexpected resource type while fetching app grants: %s", bag.ResourceTypeID())
+	}
+
+	nextPage, annos, err := parseResp(respCtx.OktaResponse)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	if bag.ResourceTypeID() == resourceTypeUser.Id && nextPage == "" {
+		o.usage.forget(appID)
+	}
+
+	err = bag.Next(nextPage)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to fetch bag.Next: %w", err)
+	}
+
+	pageToken, err := bag.Marshal()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, pageToken, annos, nil
+}
+
+func (o *appResourceType) Get(ctx context.Context, resourceId *v2.ResourceId, parentResourceId *v2.ResourceId) (*v2.Resource, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	l.Debug("getting app", zap.String("app_id", resourceId.Resource))
+
+	app, resp, err := o.connector.client.Application.GetApplication(ctx, resourceId.Resource, okta.NewApplication(), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connectorv2: failed to find app: %w", handleOktaResponseError(resp, err))
+	}
+
+	_, annos, err := parseResp(resp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	oktaApp, ok := app.(*okta.Application)
+	if !ok {
+		return nil, annos, fmt.Errorf("okta-connectorv2: unexpected app type %T", app)
+	}
+
+	if !o.connector.syncInactiveApps && oktaApp.Status == appStatusInactive {
+		return nil, annos, nil
+	}
+
+	resource, err := appResource(ctx, oktaApp)
+	if err != nil {
+		return nil, annos, err
+	}
+
+	return resource, annos, nil
+}
+
+func (o *appResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	appID := entitlement.Resource.Id.Resource
+	if roleType := roleTypeFromTargetEntitlementSlug(entitlement.Slug); slices.Contains(appTargetRoleTypes, roleType) {
+		target, err := o.appRoleTarget(ctx, appID)
+		if err != nil {
+			return nil, err
+		}
+		return grantRoleTarget(ctx, o.connector.client, principal.Id, roleType, target)
+	}
+
+	switch principal.Id.ResourceType {
+	case resourceTypeUser.Id:
+		userID := principal.Id.Resource
+		appUser, err := getAppUser(ctx, o.connector.client, appID, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if appUser != nil && appUser.Scope == appUserScopeUser {
+			l.Warn(
+				"okta-connector: The app specified is already assigned to the user",
+				zap.String("principal_id", principal.Id.String()),
+				zap.String("principal_type", principal.Id.ResourceType),
+				zap.String("app_id", appID),
+			)
+			return annotations.New(&v2.GrantAlreadyExists{}), nil
+		}
+
+		// Assigning a user that already has access through a group converts the assignment to a direct one.
+		createdAppUser, response, err := o.connector.client.Application.AssignUserToApplication(ctx, appID, okta.AppUser{
+			Id:    userID,
+			Scope: appUserScopeUser,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("okta-connector: failed to assign app to user: %w", handleOktaResponseError(response, err))
+		}
+
+		l.Warn("App Membership has been created.",
+			zap.String("app_id", appID),
+			zap.String("user_id", createdAppUser.Id),
+			zap.String("Status", createdAppUser.Status),
+			zap.String("Scope", createdAppUser.Scope),
+		)
+	case resourceTypeGroup.Id:
+		groupID := principal.Id.Resource
+		assignment, err := getAppGroupAssignment(ctx, o.connector.client, appID, groupID)
+		if err != nil {
+			return nil, err
+		}
+
+		if assignment != nil {
+			l.Warn(
+				"okta-connector: The app specified is already assigned to the group",
+				zap.String("principal_id", principal.Id.String()),
+				zap.String("principal_type", principal.Id.ResourceType),
+				zap.String("app_id", appID),
+			)
+			return annotations.New(&v2.GrantAlreadyExists{}), nil
+		}
+
+		body := okta.ApplicationGroupAssignment{}
+		if o.connector.appGroupPriority >= 0 {
+			body.PriorityPtr = ToPtr(o.connector.appGroupPriority)
+		}
+
+		createdAssignment, response, err := o.connector.client.Application.CreateApplicationGroupAssignment(ctx, appID, groupID, body)
+		if err != nil {
+			return nil, fmt.Errorf("okta-connector: failed to assign app to group: %w", handleOktaResponseError(response, err))
+		}
+
+		l.Warn("App Membership has been created.",
+			zap.String("app_id", appID),
+			zap.String("group_id", createdAssignment.Id),
+			zap.Int64("Priority", createdAssignment.Priority),
+		)
+	default:
+		l.Warn(
+			"okta-connector: only users or groups can be granted app access",
+			zap.String("principal_type", principal.Id.ResourceType),
+			zap.String("principal_id", principal.Id.Resource),
+		)
+		return nil, fmt.Errorf("okta-connector: only users or groups can be granted app access")
+	}
+
+	return nil, nil
+}
+
+func (o *appResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	principal := grant.Principal
+	appID := grant.Entitlement.Resource.Id.Resource
+	if roleType := roleTypeFromTargetEntitlementSlug(grant.Entitlement.Slug); slices.Contains(appTargetRoleTypes, roleType) {
+		target, err := o.appRoleTarget(ctx, appID)
+		if err != nil {
+			return nil, err
+		}
+		return revokeRoleTarget(ctx, o.connector.client, principal.Id, roleType, target)
+	}
+
+	switch principal.Id.ResourceType {
+	case resourceTypeUser.Id:
+		userID := principal.Id.Resource
+		appUser, err := getAppUser(ctx, o.connector.client, appID, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Group scoped assignments can only be removed by revoking the group assignment.
+		if appUser == nil || appUser.Scope != appUserScopeUser {
+			l.Warn(
+				"okta-connector: user is not directly assigned to the app",
+				zap.String("principal_id", principal.Id.String()),
+				zap.String("principal_type", principal.Id.ResourceType),
+				zap.String("app_id", appID),
+			)
+			return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+		}
+
+		response, err := o.connector.client.Application.DeleteApplicationUser(ctx, appID, userID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("okta-connector: failed to remove app from user: %w", handleOktaResponseError(response, err))
+		}
+
+		l.Warn("App Membership has been revoked",
+			zap.String("Status", response.Status),
+		)
+	case resourceTypeGroup.Id:
+		groupID := principal.Id.Resource
+		assignment, err := getAppGroupAssignment(ctx, o.connector.client, appID, groupID)
+		if err != nil {
+			return nil, err
+		}
+
+		if assignment == nil {
+			l.Warn(
+				"okta-connector: group is not assigned to the app",
+				zap.String("principal_id", principal.Id.String()),
+				zap.String("principal_type", principal.Id.ResourceType),
+				zap.String("app_id", appID),
+			)
+			return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+		}
+
+		response, err := o.connector.client.Application.DeleteApplicationGroupAssignment(ctx, appID, groupID)
+		if err != nil {
+			return nil, fmt.Errorf("okta-connector: failed to remove app from group: %w", handleOktaResponseError(response, err))
+		}
+
+		l.Warn("App Membership has been revoked",
+			zap.String("Status", response.Status),
+		)
+	default:
+		l.Warn(
+			"okta-connector: only users or groups can have app access revoked",
+			zap.String("principal_type", principal.Id.ResourceType),
+			zap.String("principal_id", principal.Id.Resource),
+		)
+		return nil, fmt.Errorf("okta-connector: only users or groups can have app access revoked")
+	}
+
+	return nil, nil
+}
+
+// appRoleTarget returns the role target for an app instance. Okta identifies app targets by catalog name and instance id.
+func (o *appResourceType) appRoleTarget(ctx context.Context, appID string) (*roleTarget, error) {
+	app, resp, err := o.connector.client.Application.GetApplication(ctx, appID, okta.NewApplication(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("okta-connector: failed to get app: %w", handleOktaResponseError(resp, err))
+	}
+
+	oktaApp, ok := app.(*okta.Application)
+	if !ok {
+		return nil, fmt.Errorf("okta-connector: unexpected app type %T", app)
+	}
+
+	return &roleTarget{AppID: oktaApp.Id, AppName: oktaApp.Name}, nil
+}
+
+// getAppUser returns nil if the user is not assigned to the app. The assignment is read past the response cache, since
+// assigning a user POSTs to the app users and leaves a cached read of the user stale.
+func getAppUser(ctx context.Context, client *okta.Client, appID string, userID string) (*okta.AppUser, error) {
+	reqUrl, err := url.JoinPath(apiPathApps, appID, "users", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	appUser := &okta.AppUser{}
+	resp, err := doUncachedRequest(ctx, client, http.MethodGet, reqUrl, nil, appUser)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("okta-connector: failed to get app user: %w", handleOktaResponseError(resp, err))
+	}
+
+	return appUser, nil
+}
+
+// getAppGroupAssignment returns nil if the group is not assigned to the app. Like getAppUser, the assignment is read
+// past the response cache.
+func getAppGroupAssignment(ctx context.Context, client *okta.Client, appID string, groupID string) (*okta.ApplicationGroupAssignment, error) {
+	reqUrl, err := url.JoinPath(apiPathApps, appID, "groups", groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := &okta.ApplicationGroupAssignment{}
+	resp, err := doUncachedRequest(ctx, client, http.MethodGet, reqUrl, nil, assignment)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("okta-connector: failed to get app group assignment: %w", handleOktaResponseError(resp, err))
+	}
+
+	return assignment, nil
+}
+
+func listApps(ctx context.Context, client *okta.Client, token *pagination.Token, qp *query.Params) ([]*okta.Application, *responseContext, error) {
+	apps, resp, err := client.Application.ListApplications(ctx, qp)
+	if err != nil {
+		return nil, nil, handleOktaResponseError(resp, err)
+	}
+
+	respCtx, err := responseToContext(token, resp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rv []*okta.Application
+	for _, app := range apps {
+		oktaApp, ok := app.(*okta.Application)
+		if !ok {
+			return nil, nil, fmt.Errorf("okta-connectorv2: unexpected app type %T", app)
+		}
+		rv = append(rv, oktaApp)
+	}
+
+	return rv, respCtx, nil
+}
+
+func listAppUsers(ctx context.Context, client *okta.Client, appID string, token *pagination.Token, qp *query.Params) ([]*okta.AppUser, *responseContext, error) {
+	appUsers, resp, err := client.Application.ListApplicationUsers(ctx, appID, qp)
+	if err != nil {
+		return nil, nil, handleOktaResponseError(resp, err)
+	}
+
+	respCtx, err := responseToContext(token, resp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return appUsers, respCtx, nil
+}
+
+func listAppGroupAssignments(
+	ctx context.Context,
+	client *okta.Client,
+	appID string,
+	token *pagination.Token,
+	qp *query.Params,
+) ([]*okta.ApplicationGroupAssignment, *responseContext, error) {
+	assignments, resp, err := client.Application.ListApplicationGroupAssignments(ctx, appID, qp)
+	if err != nil {
+		return nil, nil, handleOktaResponseError(resp, err)
+	}
+
+	respCtx, err := responseToContext(token, resp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return assignments, respCtx, nil
+}
+
+// embeddedAppUser returns the okta user embedded in an app user when listing with expand=user.
+func embeddedAppUser(appUser *okta.AppUser) (*okta.User, error) {
+	return embeddedUser(appUser.Embedded)
+}
+
+// embeddedUser returns the okta user in the _embedded field of a response listed with expand=user,
+// or nil if there is none.
+func embeddedUser(v interface{}) (*okta.User, error) {
+	embedded, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	userData, ok := embedded["user"]
+	if !ok {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(userData)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &okta.User{}
+	err = json.Unmarshal(b, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Profile == nil {
+		return nil, nil
+	}
+
+	return user, nil
+}
+
+// Create a new connector resource for an okta app.
+func appResource(ctx context.Context, app *okta.Application) (*v2.Resource, error) {
+	profile := map[string]interface{}{
+		"id":           app.Id,
+		"label":        app.Label,
+		"name":         app.Name,
+		"sign_on_mode": app.SignOnMode,
+		"status":       app.Status,
+	}
+
+	return sdkResource.NewAppResource(
+		app.Label,
+		resourceTypeApp,
+		app.Id,
+		[]sdkResource.AppTraitOption{sdkResource.WithAppProfile(profile)},
+		sdkResource.WithAnnotation(&v2.V1Identifier{
+			Id: fmtResourceIdV1(app.Id),
+		}),
+	)
+}
+
+func appUserGrant(resource *v2.Resource, appUser *okta.AppUser, opts ...sdkGrant.GrantOption) *v2.Grant {
+	ur := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: appUser.Id}}
+
+	opts = append(opts, sdkGrant.WithAnnotation(&v2.V1Identifier{
+		Id: fmtGrantIdV1(V1MembershipEntitlementID(resource.Id.Resource), appUser.Id),
+	}))
+
+	return sdkGrant.NewGrant(resource, appAccessEntitlement, ur, opts...)
+}
+
+func appGroupGrant(resource *v2.Resource, assignment *okta.ApplicationGroupAssignment) *v2.Grant {
+	gr := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeGroup.Id, Resource: assignment.Id}}
+
+	return sdkGrant.NewGrant(resource, appAccessEntitlement, gr,
+		sdkGrant.WithAnnotation(
+			&v2.V1Identifier{
+				Id: fmtGrantIdV1(V1MembershipEntitlementID(resource.Id.Resource), assignment.Id),
+			},
+			&v2.GrantExpandable{
+				EntitlementIds: []string{sdkEntitlement.NewEntitlementID(gr, groupMemberEntitlement)},
+			},
+		),
+	)
+}
+
+func appBuilder(connector *Okta) *appResourceType {
+	return &appResourceType{
+		resourceType: resourceTypeApp,
+		emailFilters: lowerEmailDomains(connector.ciamConfig.EmailDomains),
+		connector:    connector,
+	}
+}
@@ -0,0 +1,67 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_embeddedAppUser(t *testing.T) {
+	t.Run("embedded user", func(t *testing.T) {
+		appUser := &okta.AppUser{
+			Id: "00u1",
+			Embedded: map[string]interface{}{
+				"user": map[string]interface{}{
+					"id":     "00u1",
+					"status": "ACTIVE",
+					"profile": map[string]interface{}{
+						"email": "alice@foo.com",
+						"login": "alice@foo.com",
+					},
+				},
+			},
+		}
+
+		user, err := embeddedAppUser(appUser)
+		require.NoError(t, err)
+		require.NotNil(t, user)
+		require.Equal(t, "00u1", user.Id)
+		require.True(t, shouldIncludeOktaUser(user, []string{"foo.com"}))
+		require.False(t, shouldIncludeOktaUser(user, []string{"example.com"}))
+	})
+
+	t.Run("nothing embedded", func(t *testing.T) {
+		user, err := embeddedAppUser(&okta.AppUser{Id: "00u1"})
+		require.NoError(t, err)
+		require.Nil(t, user)
+	})
+}
+
+func Test_getAppUserAfterAssignment(t *testing.T) {
+	scope := "GROUP"
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/apps/0oa1/users/{userID}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("userID") != "00u1" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errorCode": "E0000007", "errorSummary": "Not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": "00u1", "scope": "` + scope + `"}`))
+	})
+	client := newTestClient(t, mux)
+	ctx := context.Background()
+
+	for _, want := range []string{"GROUP", "USER"} {
+		scope = want
+		appUser, err := getAppUser(ctx, client, "0oa1", "00u1")
+		require.NoError(t, err)
+		require.Equal(t, want, appUser.Scope)
+	}
+
+	appUser, err := getAppUser(ctx, client, "0oa1", "00u2")
+	require.NoError(t, err)
+	require.Nil(t, appUser)
+}
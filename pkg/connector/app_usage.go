@@ -0,0 +1,138 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/okta/okta-sdk-golang/v2/okta/query"
+	"go.uber.org/zap"
+)
+
+const appLastUsedMetadataKey = "last_used"
+
+// appUsageMaxPages bounds the System Log pages read for the sign ins to an app. Sign ins are read newest first, so
+// only users whose last sign in is older than the pages read go without a last used time.
+const appUsageMaxPages = 10
+
+// appUsageCache holds the last sign in of every user to an app, so that the System Log is only read once per app
+// while its user grants are paged through.
+type appUsageCache struct {
+	mu   sync.Mutex
+	apps map[string]*appUsage
+}
+
+// appUsage is the last sign in of every user to one app. The System Log is read outside of the cache lock, so only
+// syncs of the same app wait for it.
+type appUsage struct {
+	once     sync.Once
+	lastUsed map[string]time.Time
+	err      error
+}
+
+// get returns the last sign in of the users of an app, reading them from the System Log on first use.
+func (c *appUsageCache) get(ctx context.Context, client *okta.Client, appID string, lookback time.Duration) (map[string]time.Time, error) {
+	c.mu.Lock()
+	if c.apps == nil {
+		c.apps = make(map[string]*appUsage)
+	}
+	usage, ok := c.apps[appID]
+	if !ok {
+		usage = &appUsage{}
+		c.apps[appID] = usage
+	}
+	c.mu.Unlock()
+
+	usage.once.Do(func() {
+		until := time.Now().UTC()
+		usage.lastUsed, usage.err = listAppLastUsed(ctx, client, appID, until.Add(-lookback), until)
+	})
+	if usage.err != nil {
+		// Drop the failed read so the page is retried with a new one.
+		c.mu.Lock()
+		if c.apps[appID] == usage {
+			delete(c.apps, appID)
+		}
+		c.mu.Unlock()
+		return nil, usage.err
+	}
+
+	return usage.lastUsed, nil
+}
+
+// forget drops the last sign ins of an app once all of its user grants are synced.
+func (c *appUsageCache) forget(appID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.apps, appID)
+}
+
+// appUsageFilter is the System Log filter for the sign ins to an app.
+func appUsageFilter(appID string) string {
+	eventTypes := filterJoiner(" or ",
+		filterMaker("eventType", eventTypeUserAuthenticationSSO),
+		filterMaker("eventType", eventTypeUserSessionStart),
+	)
+
+	return filterJoiner(" and ", eventTypes, filterMaker("target.id", appID))
+}
+
+// listAppLastUsed reads the sign ins to an app between since and until from the System Log, newest first and at most
+// appUsageMaxPages pages of them, and returns the most recent successful one of every user.
+func listAppLastUsed(ctx context.Context, client *okta.Client, appID string, since time.Time, until time.Time) (map[string]time.Time, error) {
+	qp := query.NewQueryParams(
+		query.WithSince(since.Format(time.RFC3339)),
+		query.WithUntil(until.Format(time.RFC3339)),
+		query.WithLimit(defaultLimit),
+		query.WithFilter(appUsageFilter(appID)),
+		query.WithSortOrder("DESCENDING"),
+	)
+
+	logs, resp, err := client.LogEvent.GetLogs(ctx, qp)
+	if err != nil {
+		return nil, fmt.Errorf("okta-connectorv2: failed to list sign ins to app %s: %w", appID, handleOktaResponseError(resp, err))
+	}
+
+	rv := make(map[string]time.Time)
+	addAppLastUsed(rv, logs)
+
+	for pages := 1; resp.HasNextPage(); pages++ {
+		if pages == appUsageMaxPages {
+			ctxzap.Extract(ctx).Debug("okta-connectorv2: stopped reading sign ins to app at the page limit",
+				zap.String("app_id", appID),
+				zap.Int("pages", pages),
+			)
+			break
+		}
+
+		var next []*okta.LogEvent
+		resp, err = resp.Next(ctx, &next)
+		if err != nil {
+			return nil, fmt.Errorf("okta-connectorv2: failed to list sign ins to app %s: %w", appID, handleOktaResponseError(resp, err))
+		}
+		// Bounded queries end with an empty page.
+		if len(next) == 0 {
+			break
+		}
+		addAppLastUsed(rv, next)
+	}
+
+	return rv, nil
+}
+
+// addAppLastUsed records the most recent successful sign in of every user in logs.
+func addAppLastUsed(lastUsed map[string]time.Time, logs []*okta.LogEvent) {
+	for _, log := range logs {
+		if logEventFailed(log) || log.Published == nil || log.Actor == nil || log.Actor.Type != "User" || log.Actor.Id == "" {
+			continue
+		}
+
+		if published := log.Published.UTC(); published.After(lastUsed[log.Actor.Id]) {
+			lastUsed[log.Actor.Id] = published
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_appUsageFilter(t *testing.T) {
+	require.Equal(t, `((eventType eq "user.authentication.sso" or eventType eq "user.session.start") and target.id eq "0oa1")`,
+		appUsageFilter("0oa1"))
+}
+
+func Test_addAppLastUsed(t *testing.T) {
+	signIn := func(userID string, day int, result string) *okta.LogEvent {
+		published := time.Date(2024, 5, day, 12, 0, 0, 0, time.UTC)
+		return &okta.LogEvent{
+			Published: &published,
+			Actor:     &okta.LogActor{Type: "User", Id: userID},
+			Outcome:   &okta.LogOutcome{Result: result},
+		}
+	}
+
+	lastUsed := make(map[string]time.Time)
+	addAppLastUsed(lastUsed, []*okta.LogEvent{
+		signIn("00u1", 2, "SUCCESS"),
+		signIn("00u1", 5, "FAILURE"),
+		signIn("00u2", 3, "SUCCESS"),
+		{Actor: &okta.LogActor{Type: "User", Id: "00u3"}},
+	})
+	addAppLastUsed(lastUsed, []*okta.LogEvent{
+		signIn("00u1", 4, "SUCCESS"),
+		signIn("00u2", 1, "SUCCESS"),
+	})
+
+	require.Equal(t, map[string]time.Time{
+		"00u1": time.Date(2024, 5, 4, 12, 0, 0, 0, time.UTC),
+		"00u2": time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC),
+	}, lastUsed)
+}
+
+func Test_appUsageCache(t *testing.T) {
+	reads := map[string]int{}
+	fail := true
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/logs", func(w http.ResponseWriter, r *http.Request) {
+		appID := "0oa1"
+		if strings.Contains(r.URL.Query().Get("filter"), "0oa2") {
+			appID = "0oa2"
+		}
+		reads[appID]++
+		if appID == "0oa2" && fail {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errorCode": "E0000001", "errorSummary": "Api validation failed"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"published": "2024-05-01T12:00:00.000Z", "actor": {"type": "User", "id": "00u1"}, "outcome": {"result": "SUCCESS"}}]`))
+	})
+	client := newTestClient(t, mux, okta.WithCache(false))
+	ctx := context.Background()
+	cache := &appUsageCache{}
+
+	for range 2 {
+		lastUsed, err := cache.get(ctx, client, "0oa1", 24*time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), lastUsed["00u1"])
+	}
+	require.Equal(t, 1, reads["0oa1"])
+
+	_, err := cache.get(ctx, client, "0oa2", 24*time.Hour)
+	require.Error(t, err)
+	fail = false
+	_, err = cache.get(ctx, client, "0oa2", 24*time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 2, reads["0oa2"])
+
+	cache.forget("0oa1")
+	_, err = cache.get(ctx, client, "0oa1", 24*time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 2, reads["0oa1"])
+}
+
+func Test_listAppLastUsedPageLimit(t *testing.T) {
+	reads := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/logs", func(w http.ResponseWriter, r *http.Request) {
+		reads++
+		if reads == 1 {
+			require.Equal(t, "DESCENDING", r.URL.Query().Get("sortOrder"))
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<http://okta.test/api/v1/logs?after=%d>; rel="next"`, reads))
+		_, _ = w.Write([]byte(fmt.Sprintf(`[{"published": "2024-05-%02dT12:00:00.000Z", "actor": {"type": "User", "id": "00u%d"}, "outcome": {"result": "SUCCESS"}}]`, 28-reads, reads)))
+	})
+	client := newTestClient(t, mux, okta.WithCache(false))
+
+	until := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	lastUsed, err := listAppLastUsed(context.Background(), client, "0oa1", until.Add(-30*24*time.Hour), until)
+	require.NoError(t, err)
+	require.Equal(t, appUsageMaxPages, reads)
+	require.Len(t, lastUsed, appUsageMaxPages)
+	require.Equal(t, time.Date(2024, 5, 27, 12, 0, 0, 0, time.UTC), lastUsed["00u1"])
+}
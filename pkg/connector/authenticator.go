@@ -0,0 +1,253 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	sdkEntitlement "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	sdkGrant "github.com/conductorone/baton-sdk/pkg/types/grant"
+	sdkResource "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"go.uber.org/zap"
+)
+
+const (
+	authenticatorEnrolledEntitlement = "enrolled"
+
+	factorStatusActive = "ACTIVE"
+
+	// Profile attribute set on admin users, true when the admin has no phishing-resistant factor enrolled.
+	profileMissingPhishingResistantFactor = "c1_okta_missing_phishing_resistant_factor"
+)
+
+// Authenticators are keyed by their key in Okta (okta_password, okta_email, phone_number, okta_verify, webauthn, ...),
+// which is unique within an org and is what user factor enrollments map to.
+type authenticatorResourceType struct {
+	resourceType *v2.ResourceType
+	connector    *Okta
+}
+
+func (o *authenticatorResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return o.resourceType
+}
+
+// Authenticators aren't paginated by Okta, an org only has one of each kind.
+func (o *authenticatorResourceType) List(
+	ctx context.Context,
+	resourceID *v2.ResourceId,
+	token *pagination.Token,
+) ([]*v2.Resource, string, annotations.Annotations, error) {
+	authenticators, resp, err := o.connector.client.Authenticator.ListAuthenticators(ctx)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list authenticators: %w", handleOktaResponseError(resp, err))
+	}
+
+	_, annos, err := parseResp(resp)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	var rv []*v2.Resource
+	for _, authenticator := range authenticators {
+		resource, err := authenticatorResource(ctx, authenticator)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, resource)
+	}
+
+	return rv, "", annos, nil
+}
+
+func (o *authenticatorResourceType) Entitlements(
+	_ context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	en := sdkEntitlement.NewAssignmentEntitlement(resource, authenticatorEnrolledEntitlement,
+		sdkEntitlement.WithDisplayName(fmt.Sprintf("%s Enrolled", resource.DisplayName)),
+		sdkEntitlement.WithDescription(fmt.Sprintf("Enrolled in the %s authenticator in Okta", resource.DisplayName)),
+		sdkEntitlement.WithGrantableTo(resourceTypeUser),
+	)
+
+	return []*v2.Entitlement{en}, "", nil, nil
+}
+
+// Enrollments are emitted by the user builder from each user's factors, so every user's factors are only listed once.
+func (o *authenticatorResourceType) Grants(
+	_ context.Context,
+	_ *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func (o *authenticatorResourceType) Get(ctx context.Context, resourceId *v2.ResourceId, parentResourceId *v2.ResourceId) (*v2.Resource, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	l.Debug("getting authenticator", zap.String("authenticator_key", resourceId.Resource))
+
+	authenticators, resp, err := o.connector.client.Authenticator.ListAuthenticators(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connectorv2: failed to list authenticators: %w", handleOktaResponseError(resp, err))
+	}
+
+	_, annos, err := parseResp(resp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	for _, authenticator := range authenticators {
+		if authenticator.Key != resourceId.Resource {
+			continue
+		}
+
+		resource, err := authenticatorResource(ctx, authenticator)
+		if err != nil {
+			return nil, annos, err
+		}
+
+		return resource, annos, nil
+	}
+
+	return nil, annos, fmt.Errorf("okta-connectorv2: authenticator %s not found", resourceId.Resource)
+}
+
+// listUserFactors lists the factors a user has enrolled. The SDK returns these as typed factors, which lose
+// the provider of some factor types, so they are read as plain user factors instead.
+func listUserFactors(ctx context.Context, client *okta.Client, userID string) ([]*okta.UserFactor, error) {
+	reqUrl, err := url.JoinPath(usersUrl, userID, "factors")
+	if err != nil {
+		return nil, err
+	}
+
+	var factors []*okta.UserFactor
+	resp, err := doRequest(ctx, client, http.MethodGet, reqUrl, nil, &factors)
+	if err != nil {
+		return nil, handleOktaResponseError(resp, err)
+	}
+
+	return factors, nil
+}
+
+// factorAuthenticatorKey returns the key of the authenticator a factor is enrolled in, or an empty string when the
+// factor doesn't belong to a known authenticator. Passwords aren't factors, so there are no password enrollments.
+func factorAuthenticatorKey(factor *okta.UserFactor) string {
+	switch factor.FactorType {
+	case "email":
+		return "okta_email"
+	case "sms", "call":
+		return "phone_number"
+	case "push", "signed_nonce":
+		return "okta_verify"
+	case "token:software:totp":
+		switch factor.Provider {
+		case "OKTA":
+			return "okta_verify"
+		case "GOOGLE":
+			return "google_otp"
+		}
+		return "custom_otp"
+	case "webauthn", "u2f":
+		return "webauthn"
+	case "question":
+		return "security_question"
+	case "token:hardware":
+		return "yubikey_token"
+	case "token":
+		switch factor.Provider {
+		case "RSA":
+			return "rsa_token"
+		case "SYMANTEC":
+			return "symantec_vip"
+		}
+	case "web":
+		if factor.Provider == "DUO" {
+			return "duo"
+		}
+	}
+
+	return ""
+}
+
+// isPhishingResistantFactor reports whether a factor is bound to the origin it authenticates to, which FIDO
+// authenticators and Okta FastPass are.
+func isPhishingResistantFactor(factor *okta.UserFactor) bool {
+	switch factor.FactorType {
+	case "webauthn", "u2f", "signed_nonce":
+		return true
+	}
+
+	return false
+}
+
+// hasPhishingResistantFactor reports whether any of the active factors is phishing-resistant.
+func hasPhishingResistantFactor(factors []*okta.UserFactor) bool {
+	for _, factor := range factors {
+		if factor.Status == factorStatusActive && isPhishingResistantFactor(factor) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authenticatorEnrollmentGrants returns one grant per authenticator the user has an active factor in, with the
+// enrolled factor types as metadata.
+func authenticatorEnrollmentGrants(principal *v2.Resource, factors []*okta.UserFactor) []*v2.Grant {
+	var keys []string
+	factorTypes := make(map[string][]interface{})
+	for _, factor := range factors {
+		if factor.Status != factorStatusActive {
+			continue
+		}
+
+		key := factorAuthenticatorKey(factor)
+		if key == "" {
+			continue
+		}
+
+		if _, ok := factorTypes[key]; !ok {
+			keys = append(keys, key)
+		}
+		factorTypes[key] = append(factorTypes[key], factor.FactorType)
+	}
+
+	rv := make([]*v2.Grant, 0, len(keys))
+	for _, key := range keys {
+		ar := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeAuthenticator.Id, Resource: key}}
+		rv = append(rv, sdkGrant.NewGrant(ar, authenticatorEnrolledEntitlement, principal,
+			sdkGrant.WithGrantMetadata(map[string]interface{}{
+				"factor_types": factorTypes[key],
+			}),
+		))
+	}
+
+	return rv
+}
+
+// Create a new connector resource for an okta authenticator.
+func authenticatorResource(ctx context.Context, authenticator *okta.Authenticator) (*v2.Resource, error) {
+	return sdkResource.NewResource(
+		authenticator.Name,
+		resourceTypeAuthenticator,
+		authenticator.Key,
+		sdkResource.WithDescription(fmt.Sprintf("%s authenticator (%s)", authenticator.Type, authenticator.Status)),
+		sdkResource.WithAnnotation(&v2.V1Identifier{
+			Id: fmtResourceIdV1(authenticator.Id),
+		}),
+	)
+}
+
+func authenticatorBuilder(connector *Okta) *authenticatorResourceType {
+	return &authenticatorResourceType{
+		resourceType: resourceTypeAuthenticator,
+		connector:    connector,
+	}
+}
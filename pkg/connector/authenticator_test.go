@@ -0,0 +1,71 @@
+package connector
+
+import (
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_factorAuthenticatorKey(t *testing.T) {
+	tests := []struct {
+		factorType string
+		provider   string
+		want       string
+	}{
+		{"email", "OKTA", "okta_email"},
+		{"sms", "OKTA", "phone_number"},
+		{"call", "OKTA", "phone_number"},
+		{"push", "OKTA", "okta_verify"},
+		{"signed_nonce", "OKTA", "okta_verify"},
+		{"token:software:totp", "OKTA", "okta_verify"},
+		{"token:software:totp", "GOOGLE", "google_otp"},
+		{"webauthn", "FIDO", "webauthn"},
+		{"u2f", "FIDO", "webauthn"},
+		{"question", "OKTA", "security_question"},
+		{"token", "RSA", "rsa_token"},
+		{"token", "SYMANTEC", "symantec_vip"},
+		{"web", "DUO", "duo"},
+		{"token", "CUSTOM", ""},
+		{"unknown", "OKTA", ""},
+	}
+	for _, tt := range tests {
+		got := factorAuthenticatorKey(&okta.UserFactor{FactorType: tt.factorType, Provider: tt.provider})
+		require.Equal(t, tt.want, got, "%s/%s", tt.factorType, tt.provider)
+	}
+}
+
+func Test_hasPhishingResistantFactor(t *testing.T) {
+	require.False(t, hasPhishingResistantFactor(nil))
+	require.False(t, hasPhishingResistantFactor([]*okta.UserFactor{
+		{FactorType: "sms", Status: factorStatusActive},
+		{FactorType: "push", Status: factorStatusActive},
+		{FactorType: "webauthn", Status: "PENDING_ACTIVATION"},
+	}))
+	require.True(t, hasPhishingResistantFactor([]*okta.UserFactor{
+		{FactorType: "sms", Status: factorStatusActive},
+		{FactorType: "webauthn", Status: factorStatusActive},
+	}))
+	require.True(t, hasPhishingResistantFactor([]*okta.UserFactor{
+		{FactorType: "signed_nonce", Status: factorStatusActive},
+	}))
+}
+
+func Test_authenticatorEnrollmentGrants(t *testing.T) {
+	user := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "00u1"}}
+	grants := authenticatorEnrollmentGrants(user, []*okta.UserFactor{
+		{FactorType: "push", Provider: "OKTA", Status: factorStatusActive},
+		{FactorType: "sms", Provider: "OKTA", Status: "PENDING_ACTIVATION"},
+		{FactorType: "signed_nonce", Provider: "OKTA", Status: factorStatusActive},
+		{FactorType: "webauthn", Provider: "FIDO", Status: factorStatusActive},
+		{FactorType: "unknown", Provider: "OKTA", Status: factorStatusActive},
+	})
+
+	require.Len(t, grants, 2)
+	require.Equal(t, "authenticator:okta_verify:enrolled", grants[0].Entitlement.Id)
+	require.Equal(t, "authenticator:webauthn:enrolled", grants[1].Entitlement.Id)
+	for _, grant := range grants {
+		require.Equal(t, "00u1", grant.Principal.Id.Resource)
+	}
+}
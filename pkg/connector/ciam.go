@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"net/http"
 	"slices"
+	"sync"
+	"time"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
@@ -17,8 +19,7 @@ import (
 )
 
 type ciamResourceBuilder struct {
-	client              *okta.Client
-	skipSecondaryEmails bool
+	connector *Okta
 }
 
 func (o *ciamResourceBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
@@ -45,7 +46,7 @@ func (o *ciamResourceBuilder) List(ctx context.Context, parentResourceID *v2.Res
 	switch current.ResourceTypeID {
 	case resourceTypeUser.Id:
 		if current.ResourceID != "" {
-			oktaUser, resp, err := o.client.User.GetUser(ctx, current.ResourceID)
+			oktaUser, resp, err := o.connector.client.User.GetUser(ctx, current.ResourceID)
 			if err != nil {
 				return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to get user: %w", err)
 			}
@@ -54,14 +55,14 @@ func (o *ciamResourceBuilder) List(ctx context.Context, parentResourceID *v2.Res
 				return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
 			}
 
-			resource, err := userResource(ctx, oktaUser, o.skipSecondaryEmails)
+			resource, err := o.connector.userResource(ctx, oktaUser)
 			if err != nil {
 				return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to create user resource: %w", err)
 			}
 			rv = append(rv, resource)
 			bag.Pop()
 		} else {
-			adminFlags, respCtx, err := listAdministratorRoleFlags(ctx, o.client, pToken, current.Token)
+			adminIDs, nextPage, respAnnos, err := o.connector.listAdminUserIDs(ctx, pToken, current.Token)
 			if err != nil {
 				// We don't have permissions to fetch role assignments, so return an empty list
 				if errors.Is(err, errMissingRolePermissions) {
@@ -71,11 +72,6 @@ func (o *ciamResourceBuilder) List(ctx context.Context, parentResourceID *v2.Res
 				return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list users: %w", err)
 			}
 
-			nextPage, respAnnos, err := parseAdminListResp(respCtx.OktaResponse)
-			if err != nil {
-				return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
-			}
-
 			err = bag.Next(nextPage)
 			if err != nil {
 				return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to fetch bag.Next: %w", err)
@@ -83,10 +79,10 @@ func (o *ciamResourceBuilder) List(ctx context.Context, parentResourceID *v2.Res
 
 			annos = respAnnos
 
-			for _, administratorRoleFlag := range adminFlags {
+			for _, adminID := range adminIDs {
 				bag.Push(pagination.PageState{
 					ResourceTypeID: resourceTypeUser.Id,
-					ResourceID:     administratorRoleFlag.UserId,
+					ResourceID:     adminID,
 				})
 			}
 		}
@@ -122,7 +118,7 @@ func (o *ciamResourceBuilder) Entitlements(ctx context.Context, resource *v2.Res
 		sdkEntitlement.WithAnnotation(&v2.V1Identifier{
 			Id: V1MembershipEntitlementID(role.Type),
 		}),
-		sdkEntitlement.WithGrantableTo(resourceTypeUser),
+		sdkEntitlement.WithGrantableTo(resourceTypeUser, resourceTypeGroup),
 	)
 
 	rv = append(rv, en)
@@ -130,56 +126,275 @@ func (o *ciamResourceBuilder) Entitlements(ctx context.Context, resource *v2.Res
 	return rv, "", nil, nil
 }
 
+// The role grants of every user returned by the IAM assignees API are made while syncing the grants of the first
+// standard role, so the roles and targets of each assignee are only read once per sync.
+var assigneeRoleGrantsRoleType = standardRoleTypes[0].Type
+
+// Page state of the assignee pass, to tell it apart from the role flags read for every role from the administrators
+// endpoint.
+const roleGrantsAssignees = "assignees"
+
 func (o *ciamResourceBuilder) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	var rv []*v2.Grant
-	bag, page, err := parsePageToken(pToken.Token, resource.Id)
+	bag := &pagination.Bag{}
+	err := bag.Unmarshal(pToken.Token)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse page token: %w", err)
 	}
 
-	adminFlags, respCtx, err := listAdministratorRoleFlags(ctx, o.client, pToken, page)
+	// Assignments to groups are granted by the group syncer, which reads the roles of every group once instead of
+	// once per role.
+	if bag.Current() == nil {
+		if o.connector.useAdministratorsEndpoint {
+			bag.Push(pagination.PageState{
+				ResourceTypeID: resourceTypeUser.Id,
+			})
+		}
+		if resource.Id.GetResource() == assigneeRoleGrantsRoleType && (!o.connector.useAdministratorsEndpoint || o.connector.syncCustomRoles) {
+			bag.Push(pagination.PageState{
+				ResourceTypeID: resourceTypeUser.Id,
+				ResourceID:     roleGrantsAssignees,
+			})
+		}
+	}
+
+	current := bag.Current()
+	if current == nil {
+		return nil, "", nil, nil
+	}
+
+	var rv []*v2.Grant
+	var annos annotations.Annotations
+
+	switch current.ResourceTypeID {
+	case resourceTypeUser.Id:
+		var nextPage string
+		if current.ResourceID == roleGrantsAssignees {
+			rv, nextPage, annos, err = o.listAssigneeRoleGrants(ctx, pToken, current.Token)
+		} else {
+			rv, nextPage, annos, err = o.listAdministratorRoleGrants(ctx, resource, pToken, current.Token)
+		}
+		if err != nil {
+			// We don't have permissions to fetch role assignments, so skip user grants
+			if errors.Is(err, errMissingRolePermissions) {
+				bag.Pop()
+				break
+			}
+			return nil, "", nil, err
+		}
+
+		err = bag.Next(nextPage)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to fetch bag.Next: %w", err)
+		}
+	default:
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: unexpected resource type while fetching role grants: %s", current.ResourceTypeID)
+	}
+
+	nextPageToken, err := bag.Marshal()
 	if err != nil {
-		// We don't have permissions to fetch role assignments, so return an empty list
-		if errors.Is(err, errMissingRolePermissions) {
-			return nil, "", nil, nil
+		return nil, "", nil, err
+	}
+
+	return rv, nextPageToken, annos, nil
+}
+
+// listAdminUserIDs returns the ids of users with at least one admin role assignment.
+func (c *Okta) listAdminUserIDs(ctx context.Context, pToken *pagination.Token, page string) ([]string, string, annotations.Annotations, error) {
+	var adminIDs []string
+	if c.useAdministratorsEndpoint {
+		adminFlags, respCtx, err := listAdministratorRoleFlags(ctx, c.client, pToken, page)
+		if err != nil {
+			return nil, "", nil, err
 		}
-		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list users: %w", err)
+
+		nextPage, annos, err := parseAdminListResp(respCtx.OktaResponse)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+		}
+
+		for _, administratorRoleFlag := range adminFlags {
+			adminIDs = append(adminIDs, administratorRoleFlag.UserId)
+		}
+
+		return adminIDs, nextPage, annos, nil
 	}
 
-	nextPage, annos, err := parseAdminListResp(respCtx.OktaResponse)
+	assignees, nextPage, respCtx, err := listUsersWithRoleAssignments(ctx, c.client, pToken, page)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	_, annos, err := parseResp(respCtx.OktaResponse)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
 	}
 
-	err = bag.Next(nextPage)
+	for _, assignee := range assignees {
+		adminIDs = append(adminIDs, assignee.ID)
+	}
+
+	return adminIDs, nextPage, annos, nil
+}
+
+// adminUserCacheTTL is how long the listed admin users are used for before they are listed again.
+const adminUserCacheTTL = 5 * time.Minute
+
+// adminUserCache holds the ids of the users with an admin role assignment, so that every user resource can tell
+// whether its user is an admin without reading the user's roles.
+type adminUserCache struct {
+	mu       sync.Mutex
+	adminIDs map[string]bool
+	listedAt time.Time
+}
+
+// isAdminUser reports whether a user has an admin role assignment, listing the admin users when they haven't been
+// listed within adminUserCacheTTL. Missing permissions to read role assignments are cached as no admin users.
+func (c *Okta) isAdminUser(ctx context.Context, userID string) (bool, error) {
+	c.adminUsers.mu.Lock()
+	defer c.adminUsers.mu.Unlock()
+
+	if c.adminUsers.adminIDs == nil || time.Since(c.adminUsers.listedAt) > adminUserCacheTTL {
+		adminIDs := make(map[string]bool)
+		token := newPaginationToken(defaultLimit, "")
+		page := ""
+		for {
+			ids, nextPage, _, err := c.listAdminUserIDs(ctx, token, page)
+			if err != nil {
+				if !errors.Is(err, errMissingRolePermissions) {
+					return false, fmt.Errorf("okta-connectorv2: failed to list admin users: %w", err)
+				}
+				ctxzap.Extract(ctx).Warn("okta-connectorv2: missing role permissions, admin users are not checked for phishing-resistant factors")
+				break
+			}
+			for _, id := range ids {
+				adminIDs[id] = true
+			}
+			if nextPage == "" {
+				break
+			}
+			page = nextPage
+		}
+
+		c.adminUsers.adminIDs = adminIDs
+		c.adminUsers.listedAt = time.Now()
+	}
+
+	return c.adminUsers.adminIDs[userID], nil
+}
+
+// listAssigneeRoleGrants reads the roles of every user returned by the IAM assignees API and returns the grants of
+// all of them.
+func (o *ciamResourceBuilder) listAssigneeRoleGrants(
+	ctx context.Context,
+	pToken *pagination.Token,
+	page string,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	assignees, nextPage, respCtx, err := listUsersWithRoleAssignments(ctx, o.connector.client, pToken, page)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to fetch bag.Next: %w", err)
+		if errors.Is(err, errMissingRolePermissions) {
+			return nil, "", nil, err
+		}
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list users with role assignments: %w", err)
 	}
 
-	for _, administratorRoleFlag := range adminFlags {
-		if userHasRoleAccess(administratorRoleFlag, resource) {
-			userID := administratorRoleFlag.UserId
-			rv = append(rv, roleGrant(userID, resource))
+	_, annos, err := parseResp(respCtx.OktaResponse)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	var rv []*v2.Grant
+	for _, assignee := range assignees {
+		roles, err := listUserAssignedRoles(ctx, o.connector.client, assignee.ID)
+		if err != nil {
+			if errors.Is(err, errMissingRolePermissions) {
+				return nil, "", nil, err
+			}
+			return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list user roles: %w", err)
 		}
+
+		grants, err := o.userRoleGrants(ctx, assignee.ID, roles)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		rv = append(rv, grants...)
 	}
 
-	nextPageToken, err := bag.Marshal()
+	return rv, nextPage, annos, nil
+}
+
+// userRoleGrants returns the grants of the roles assigned directly to a user. Standard roles are granted here unless
+// they are read from the administrators endpoint, along with the role target entitlements of the groups and apps
+// they are scoped to. Custom roles are granted when they are synced.
+func (o *ciamResourceBuilder) userRoleGrants(ctx context.Context, userID string, roles []*Roles) ([]*v2.Grant, error) {
+	principalID := &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: userID}
+
+	var rv []*v2.Grant
+	for _, role := range roles {
+		if role.AssignmentType != roleAssignmentTypeUser || role.Status != roleStatusActive {
+			continue
+		}
+
+		switch {
+		case standardRoleFromType(role.Type) != nil && !o.connector.useAdministratorsEndpoint:
+			targets, err := listRoleTargets(ctx, o.connector.client, principalID, role)
+			if err != nil {
+				return nil, fmt.Errorf("okta-connectorv2: failed to list user role targets: %w", err)
+			}
+
+			rr := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeRole.Id, Resource: role.Type}}
+			rv = append(rv, roleGrant(userID, rr, roleAssignmentGrantMetadata(role, targets)))
+			rv = append(rv, roleTargetGrants(principalID, role, targets)...)
+		case role.Type == roleTypeCustom && o.connector.syncCustomRoles:
+			rr := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeCustomRole.Id, Resource: role.Role}}
+			rv = append(rv, roleGrant(userID, rr, roleAssignmentGrantMetadata(role, nil)))
+		}
+	}
+
+	return rv, nil
+}
+
+// listAdministratorRoleGrants finds individual assignments of a standard role from the internal administrators
+// endpoint. The endpoint only reports role flags, so grants don't carry assignment ids, timestamps or specific targets.
+func (o *ciamResourceBuilder) listAdministratorRoleGrants(
+	ctx context.Context,
+	resource *v2.Resource,
+	pToken *pagination.Token,
+	page string,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	adminFlags, respCtx, err := listAdministratorRoleFlags(ctx, o.connector.client, pToken, page)
 	if err != nil {
-		return nil, "", nil, err
+		if errors.Is(err, errMissingRolePermissions) {
+			return nil, "", nil, err
+		}
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list users: %w", err)
 	}
 
-	return rv, nextPageToken, annos, nil
+	nextPage, annos, err := parseAdminListResp(respCtx.OktaResponse)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	var rv []*v2.Grant
+	for _, administratorRoleFlag := range adminFlags {
+		if userHasRoleAccess(administratorRoleFlag, resource) {
+			role := &Roles{AssignmentType: roleAssignmentTypeUser}
+			targets := roleTargetsFromFlags(administratorRoleFlag, resource.Id.GetResource())
+			rv = append(rv, roleGrant(administratorRoleFlag.UserId, resource, roleAssignmentGrantMetadata(role, targets)))
+		}
+	}
+
+	return rv, nextPage, annos, nil
 }
 
 func (g *ciamResourceBuilder) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
-	if principal.Id.ResourceType != resourceTypeUser.Id {
+	if principal.Id.ResourceType != resourceTypeUser.Id && principal.Id.ResourceType != resourceTypeGroup.Id {
 		l.Warn(
 			"okta-connector: only users or groups can be granted role membership",
 			zap.String("principal_type", principal.Id.ResourceType),
 			zap.String("principal_id", principal.Id.Resource),
 		)
-		return nil, fmt.Errorf("okta-connector: only users or groups can be granted repo membership")
+		return nil, fmt.Errorf("okta-connector: only users or groups can be granted role membership")
 	}
 
 	roleId := entitlement.Resource.Id.Resource
@@ -189,7 +404,7 @@ func (g *ciamResourceBuilder) Grant(ctx context.Context, principal *v2.Resource,
 		role := okta.AssignRoleRequest{
 			Type: roleId,
 		}
-		createdRole, response, err := g.client.User.AssignRoleToUser(ctx, userId, role, nil)
+		createdRole, response, err := g.connector.client.User.AssignRoleToUser(ctx, userId, role, nil)
 		if err != nil {
 			defer response.Body.Close()
 			errOkta, err := getError(response)
@@ -224,7 +439,7 @@ func (g *ciamResourceBuilder) Grant(ctx context.Context, principal *v2.Resource,
 		role := okta.AssignRoleRequest{
 			Type: roleId,
 		}
-		createdRole, response, err := g.client.Group.AssignRoleToGroup(ctx, groupId, role, nil)
+		createdRole, response, err := g.connector.client.Group.AssignRoleToGroup(ctx, groupId, role, nil)
 		if err != nil {
 			defer response.Body.Close()
 			errOkta, err := getError(response)
@@ -266,7 +481,7 @@ func (g *ciamResourceBuilder) Revoke(ctx context.Context, grant *v2.Grant) (anno
 	entitlement := grant.Entitlement
 	principal := grant.Principal
 	roleId := ""
-	if principal.Id.ResourceType != resourceTypeUser.Id {
+	if principal.Id.ResourceType != resourceTypeUser.Id && principal.Id.ResourceType != resourceTypeGroup.Id {
 		l.Warn(
 			"okta-connector: only users or groups can have role membership revoked",
 			zap.String("principal_type", principal.Id.ResourceType),
@@ -279,13 +494,13 @@ func (g *ciamResourceBuilder) Revoke(ctx context.Context, grant *v2.Grant) (anno
 	switch principal.Id.ResourceType {
 	case resourceTypeUser.Id:
 		userId := principal.Id.Resource
-		roles, response, err := g.client.User.ListAssignedRolesForUser(ctx, userId, nil)
+		roles, response, err := g.connector.client.User.ListAssignedRolesForUser(ctx, userId, nil)
 		if err != nil {
 			return nil, fmt.Errorf("okta-connector: failed to get roles: %s %s", err.Error(), response.Body)
 		}
 
 		rolePos := slices.IndexFunc(roles, func(r *okta.Role) bool {
-			return r.Type == roleType && r.Status == userStatusActive
+			return r.Type == roleType && r.Status == roleStatusActive
 		})
 		if rolePos == NF {
 			l.Warn(
@@ -298,7 +513,7 @@ func (g *ciamResourceBuilder) Revoke(ctx context.Context, grant *v2.Grant) (anno
 		}
 
 		roleId = roles[rolePos].Id
-		response, err = g.client.User.RemoveRoleFromUser(ctx, userId, roleId)
+		response, err = g.connector.client.User.RemoveRoleFromUser(ctx, userId, roleId)
 		if err != nil {
 			return nil, fmt.Errorf("okta-connector: failed to remove role: %s %s", err.Error(), response.Body)
 		}
@@ -310,13 +525,13 @@ func (g *ciamResourceBuilder) Revoke(ctx context.Context, grant *v2.Grant) (anno
 		}
 	case resourceTypeGroup.Id:
 		groupId := principal.Id.Resource
-		roles, response, err := g.client.Group.ListGroupAssignedRoles(ctx, groupId, nil)
+		roles, response, err := g.connector.client.Group.ListGroupAssignedRoles(ctx, groupId, nil)
 		if err != nil {
 			return nil, fmt.Errorf("okta-connector: failed to get roles: %s %s", err.Error(), response.Body)
 		}
 
 		rolePos := slices.IndexFunc(roles, func(r *okta.Role) bool {
-			return r.Type == roleType && r.Status == userStatusActive
+			return r.Type == roleType && r.Status == roleStatusActive
 		})
 		if rolePos == NF {
 			l.Warn(
@@ -329,7 +544,7 @@ func (g *ciamResourceBuilder) Revoke(ctx context.Context, grant *v2.Grant) (anno
 		}
 
 		roleId = roles[rolePos].Id
-		response, err = g.client.Group.RemoveRoleFromGroup(ctx, groupId, roleId)
+		response, err = g.connector.client.Group.RemoveRoleFromGroup(ctx, groupId, roleId)
 		if err != nil {
 			return nil, fmt.Errorf("okta-connector: failed to remove role: %s %s", err.Error(), response.Body)
 		}
@@ -367,9 +582,8 @@ func (o *ciamResourceBuilder) ResourceType(ctx context.Context) *v2.ResourceType
 	return resourceTypeRole
 }
 
-func ciamBuilder(client *okta.Client, skipSecondaryEmails bool) *ciamResourceBuilder {
+func ciamBuilder(connector *Okta) *ciamResourceBuilder {
 	return &ciamResourceBuilder{
-		client:              client,
-		skipSecondaryEmails: skipSecondaryEmails,
+		connector: connector,
 	}
 }
@@ -0,0 +1,120 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ciamListAdminWithoutProfile(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/iam/assignees/users", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"value": [{"id": "00u1"}]}`))
+	})
+	mux.HandleFunc("GET /api/v1/users/00u1", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id": "00u1", "status": "ACTIVE"}`))
+	})
+	mux.HandleFunc("GET /api/v1/users/00u1/factors", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[]`))
+	})
+	o := ciamBuilder(&Okta{client: newTestClient(t, mux)})
+
+	bag := &pagination.Bag{}
+	bag.Push(pagination.PageState{ResourceTypeID: resourceTypeUser.Id, ResourceID: "00u1"})
+	token, err := bag.Marshal()
+	require.NoError(t, err)
+
+	resources, _, _, err := o.List(context.Background(), nil, &pagination.Token{Token: token})
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+
+	userTrait, err := resource.GetUserTrait(resources[0])
+	require.NoError(t, err)
+	require.True(t, userTrait.GetProfile().GetFields()[profileMissingPhishingResistantFactor].GetBoolValue())
+}
+
+func Test_userResourceFlagsOnlyAdmins(t *testing.T) {
+	assigneeReads := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/iam/assignees/users", func(w http.ResponseWriter, r *http.Request) {
+		assigneeReads++
+		_, _ = w.Write([]byte(`{"value": [{"id": "00u1"}]}`))
+	})
+	mux.HandleFunc("GET /api/v1/users/00u1/factors", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"id": "fac1", "factorType": "webauthn", "status": "ACTIVE"}]`))
+	})
+	o := &Okta{client: newTestClient(t, mux, okta.WithCache(false))}
+	ctx := context.Background()
+
+	admin, err := o.userResource(ctx, &okta.User{Id: "00u1", Status: "ACTIVE"})
+	require.NoError(t, err)
+	userTrait, err := resource.GetUserTrait(admin)
+	require.NoError(t, err)
+	missing, ok := userTrait.GetProfile().GetFields()[profileMissingPhishingResistantFactor]
+	require.True(t, ok)
+	require.False(t, missing.GetBoolValue())
+
+	user, err := o.userResource(ctx, &okta.User{Id: "00u2", Status: "ACTIVE"})
+	require.NoError(t, err)
+	userTrait, err = resource.GetUserTrait(user)
+	require.NoError(t, err)
+	require.NotContains(t, userTrait.GetProfile().GetFields(), profileMissingPhishingResistantFactor)
+	require.Equal(t, 1, assigneeReads)
+}
+
+func Test_ciamGrantsReadsAssigneesOnce(t *testing.T) {
+	roleReads := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/iam/assignees/users", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"value": [{"id": "00u1"}, {"id": "00u2"}]}`))
+	})
+	mux.HandleFunc("GET /api/v1/users/00u1/roles", func(w http.ResponseWriter, r *http.Request) {
+		roleReads++
+		_, _ = w.Write([]byte(`[{"id": "ra1", "type": "SUPER_ADMIN", "status": "ACTIVE", "assignmentType": "USER"}]`))
+	})
+	mux.HandleFunc("GET /api/v1/users/00u2/roles", func(w http.ResponseWriter, r *http.Request) {
+		roleReads++
+		_, _ = w.Write([]byte(`[
+			{"id": "ra2", "type": "HELP_DESK_ADMIN", "status": "ACTIVE", "assignmentType": "USER"},
+			{"id": "ra3", "type": "READ_ONLY_ADMIN", "status": "ACTIVE", "assignmentType": "GROUP"}
+		]`))
+	})
+	mux.HandleFunc("GET /api/v1/users/00u2/roles/ra2/targets/groups", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"id": "00g1"}]`))
+	})
+	o := ciamBuilder(&Okta{client: newTestClient(t, mux, okta.WithCache(false))})
+	ctx := context.Background()
+
+	grants := func(roleType string) []string {
+		resource := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeRole.Id, Resource: roleType}}
+		var rv []string
+		token := ""
+		for {
+			page, next, _, err := o.Grants(ctx, resource, &pagination.Token{Size: 50, Token: token})
+			require.NoError(t, err)
+			for _, grant := range page {
+				rv = append(rv, grant.Entitlement.Id+"@"+grant.Principal.Id.Resource)
+			}
+			if next == "" {
+				return rv
+			}
+			token = next
+		}
+	}
+
+	require.Empty(t, grants("SUPER_ADMIN"))
+	require.Equal(t, 0, roleReads)
+
+	require.Equal(t, []string{
+		"role:SUPER_ADMIN:assigned@00u1",
+		"role:HELP_DESK_ADMIN:assigned@00u2",
+		"group:00g1:help_desk_admin@00u2",
+	}, grants(assigneeRoleGrantsRoleType))
+	require.Equal(t, 2, roleReads)
+}
@@ -5,8 +5,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/conductorone/baton-okta-ciam/pkg/config"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/actions"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	"github.com/conductorone/baton-sdk/pkg/uhttp"
@@ -17,8 +22,26 @@ type Okta struct {
 	client              *okta.Client
 	domain              string
 	apiToken            string
+	clientId            string
+	scopes              []string
 	ciamConfig          *ciamConfig
 	skipSecondaryEmails bool
+	syncInactiveApps    bool
+	appGroupPriority    int64
+	syncCustomRoles     bool
+	// useAdministratorsEndpoint makes role discovery use /api/internal/administrators instead of the IAM assignees API.
+	useAdministratorsEndpoint bool
+	accountTypeRules          []*accountTypeRule
+	userTypes                 userTypeCache
+	accountCreationSchemas    accountCreationSchemaCache
+	adminUsers                adminUserCache
+	syncIdentityProviders     bool
+	syncAuthenticators        bool
+	deactivateOnlyOnDelete    bool
+	noPasswordRotation        string
+	eventFeeds                []*oktaEventFeed
+	appUsageLookback          time.Duration
+	actionManager             *actions.ActionManager
 }
 
 type ciamConfig struct {
@@ -28,14 +51,47 @@ type ciamConfig struct {
 type Config struct {
 	Domain           string
 	ApiToken         string
+	OktaClientId     string
+	OktaPrivateKey   string
+	OktaPrivateKeyId string
+	OktaScopes       []string
 	CiamEmailDomains []string
 
 	Cache               bool
 	CacheTTI            int32
 	CacheTTL            int32
 	SkipSecondaryEmails bool
+	SyncInactiveApps    bool
+	AppGroupPriority    int64
+	SyncCustomRoles     bool
+
+	UseAdministratorsEndpoint bool
+	AccountTypeRules          []string
+	SyncIdentityProviders     bool
+	SyncAuthenticators        bool
+	DeactivateOnlyOnDelete    bool
+	NoPasswordRotation        string
+	EventFilterGroups         []string
+	ExtraEventTypes           []string
+	AppUsageLookbackDays      int64
+}
+
+// Scopes the connector needs in order to sync when authenticating with a private key.
+var requiredOAuthScopes = []string{
+	"okta.users.read",
+	"okta.groups.read",
+	"okta.apps.read",
+	"okta.roles.read",
+	"okta.orgs.read",
+	"okta.logs.read",
 }
 
+// Scope needed to list authenticators, which isn't granted by default.
+const authenticatorsOAuthScope = "okta.authenticators.read"
+
+// Scope needed to list identity providers and their users.
+const identityProvidersOAuthScope = "okta.idps.read"
+
 func v1AnnotationsForResourceType(resourceTypeID string, skipEntitlementsAndGrants bool) annotations.Annotations {
 	annos := annotations.Annotations{}
 	annos.Update(&v2.V1Identifier{
@@ -62,25 +118,92 @@ var (
 		Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_USER},
 		Annotations: v1AnnotationsForResourceType("user", true),
 	}
+	// Users have grants when authenticators are synced, since their factor enrollments are emitted from the user builder.
+	resourceTypeUserWithGrants = &v2.ResourceType{
+		Id:          "user",
+		DisplayName: "User",
+		Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_USER},
+		Annotations: v1AnnotationsForResourceType("user", false),
+	}
 	resourceTypeGroup = &v2.ResourceType{
 		Id:          "group",
 		DisplayName: "Group",
 		Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_GROUP},
 		Annotations: v1AnnotationsForResourceType("group", false),
 	}
+	resourceTypeApp = &v2.ResourceType{
+		Id:          "app",
+		DisplayName: "App",
+		Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_APP},
+		Annotations: v1AnnotationsForResourceType("app", false),
+	}
+	resourceTypeCustomRole = &v2.ResourceType{
+		Id:          "custom-role",
+		DisplayName: "Custom Role",
+		Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_ROLE},
+		Annotations: v1AnnotationsForResourceType("custom-role", false),
+	}
+	resourceTypeUserType = &v2.ResourceType{
+		Id:          "user-type",
+		DisplayName: "User Type",
+		Annotations: v1AnnotationsForResourceType("user-type", true),
+	}
+	resourceTypeIdentityProvider = &v2.ResourceType{
+		Id:          "identity-provider",
+		DisplayName: "Identity Provider",
+		Annotations: v1AnnotationsForResourceType("identity-provider", false),
+	}
+	resourceTypeAuthenticator = &v2.ResourceType{
+		Id:          "authenticator",
+		DisplayName: "Authenticator",
+		Annotations: v1AnnotationsForResourceType("authenticator", false),
+	}
+	resourceTypeResourceSet = &v2.ResourceType{
+		Id:          "resource-set",
+		DisplayName: "Resource Set",
+		Annotations: v1AnnotationsForResourceType("resource-set", false),
+	}
+	resourceTypeResourceSetBinding = &v2.ResourceType{
+		Id:          "resourceset-binding",
+		DisplayName: "Resource Set Binding",
+		Annotations: v1AnnotationsForResourceType("resourceset-binding", false),
+	}
 )
 
 func (o *Okta) ResourceSyncers(ctx context.Context) []connectorbuilder.ResourceSyncer {
-	return []connectorbuilder.ResourceSyncer{
+	resourceSyncers := []connectorbuilder.ResourceSyncer{
 		ciamUserBuilder(o),
-		ciamBuilder(o.client, o.skipSecondaryEmails),
+		userTypeBuilder(o),
+		groupBuilder(o),
+		appBuilder(o),
+		ciamBuilder(o),
 	}
+
+	if o.syncIdentityProviders {
+		resourceSyncers = append(resourceSyncers, identityProviderBuilder(o))
+	}
+
+	if o.syncAuthenticators {
+		resourceSyncers = append(resourceSyncers, authenticatorBuilder(o))
+	}
+
+	if o.syncCustomRoles {
+		resourceSyncers = append(resourceSyncers,
+			customRoleBuilder(o),
+			resourceSetBuilder(o),
+			resourceSetBindingBuilder(o),
+		)
+	}
+
+	return resourceSyncers
 }
 
 func (c *Okta) ListResourceTypes(ctx context.Context, request *v2.ResourceTypesServiceListResourceTypesRequest) (*v2.ResourceTypesServiceListResourceTypesResponse, error) {
-	resourceTypes := []*v2.ResourceType{
-		resourceTypeUser,
-		resourceTypeGroup,
+	// The resource types are taken from the syncers, so the list follows the enabled sync options.
+	syncers := c.ResourceSyncers(ctx)
+	resourceTypes := make([]*v2.ResourceType, 0, len(syncers))
+	for _, syncer := range syncers {
+		resourceTypes = append(resourceTypes, syncer.ResourceType(ctx))
 	}
 
 	return &v2.ResourceTypesServiceListResourceTypesResponse{
@@ -99,76 +222,47 @@ func (c *Okta) Metadata(ctx context.Context) (*v2.ConnectorMetadata, error) {
 		Url: c.domain,
 	})
 
+	accountCreationSchema := c.accountCreationSchema(ctx)
+	if len(c.ciamConfig.EmailDomains) > 0 {
+		order := int32(len(accountCreationSchema.FieldMap) + 1)
+		accountCreationSchema.FieldMap[accountFieldAllowOutsideEmailDomains] = allowOutsideEmailDomainsField(order)
+	}
+
 	return &v2.ConnectorMetadata{
-		DisplayName: "Okta",
-		Description: "The Okta connector syncs user, group, role, and app data from Okta",
-		Annotations: annos,
-		AccountCreationSchema: &v2.ConnectorAccountCreationSchema{
-			FieldMap: map[string]*v2.ConnectorAccountCreationSchema_Field{
-				"first_name": {
-					DisplayName: "First Name",
-					Required:    true,
-					Description: "This first name will be used for the user.",
-					Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
-						StringField: &v2.ConnectorAccountCreationSchema_StringField{},
-					},
-					Placeholder: "First name",
-					Order:       1,
-				},
-				"last_name": {
-					DisplayName: "Last Name",
-					Required:    true,
-					Description: "This last name will be used for the user.",
-					Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
-						StringField: &v2.ConnectorAccountCreationSchema_StringField{},
-					},
-					Placeholder: "Last name",
-					Order:       2,
-				},
-				"email": {
-					DisplayName: "Email",
-					Required:    true,
-					Description: "This will be the email of the user. If login is unset this is also the login.",
-					Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
-						StringField: &v2.ConnectorAccountCreationSchema_StringField{},
-					},
-					Placeholder: "Email",
-					Order:       3,
-				},
-				"login": {
-					DisplayName: "Login",
-					Required:    false,
-					Description: "This login will be used as the login for the user. Email will be used if login is not present.",
-					Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
-						StringField: &v2.ConnectorAccountCreationSchema_StringField{},
-					},
-					Placeholder: "Login",
-					Order:       4,
-				},
-				"password_change_on_login_required": {
-					DisplayName: "Password Change Required on Login",
-					Required:    false,
-					Description: "When creating accounts with a random password setting this to 'true' will require the user to change their password on first login.",
-					Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
-						StringField: &v2.ConnectorAccountCreationSchema_StringField{},
-					},
-					Placeholder: "True/False",
-					Order:       5,
-				},
-			},
-		},
+		DisplayName:           "Okta",
+		Description:           "The Okta connector syncs user, group, role, and app data from Okta",
+		Annotations:           annos,
+		AccountCreationSchema: accountCreationSchema,
 	}, nil
 }
 
 func (c *Okta) Validate(ctx context.Context) (annotations.Annotations, error) {
-	if c.apiToken == "" {
+	if c.apiToken == "" && c.clientId == "" {
 		return nil, nil
 	}
 
+	if c.clientId != "" {
+		requiredScopes := slices.Clone(requiredOAuthScopes)
+		if c.syncIdentityProviders {
+			requiredScopes = append(requiredScopes, identityProvidersOAuthScope)
+		}
+		if c.syncAuthenticators {
+			requiredScopes = append(requiredScopes, authenticatorsOAuthScope)
+		}
+		missing := missingScopes(c.scopes, requiredScopes)
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("okta-connector: verify failed, missing required oauth scopes: %s", strings.Join(missing, ", "))
+		}
+	}
+
 	token := newPaginationToken(defaultLimit, "")
 
 	_, respCtx, err := getOrgSettings(ctx, c.client, token)
 	if err != nil {
+		if c.clientId != "" {
+			return nil, fmt.Errorf("okta-connector: verify failed to fetch org, check that the service app has been granted the scopes %s: %w",
+				strings.Join(c.scopes, ", "), err)
+		}
 		return nil, fmt.Errorf("okta-connector: verify failed to fetch org: %w", err)
 	}
 
@@ -185,6 +279,18 @@ func (c *Okta) Validate(ctx context.Context) (annotations.Annotations, error) {
 	return nil, nil
 }
 
+// missingScopes returns the required scopes that are not present in the granted scopes.
+func missingScopes(granted []string, required []string) []string {
+	var missing []string
+	for _, scope := range required {
+		if !slices.Contains(granted, scope) {
+			missing = append(missing, scope)
+		}
+	}
+
+	return missing
+}
+
 func (c *Okta) Asset(ctx context.Context, asset *v2.AssetRef) (string, io.ReadCloser, error) {
 	return "", nil, fmt.Errorf("not implemented")
 }
@@ -198,29 +304,92 @@ func New(ctx context.Context, cfg *Config) (*Okta, error) {
 		return nil, err
 	}
 
-	if cfg.ApiToken != "" && cfg.Domain != "" {
-		_, oktaClient, err = okta.NewClient(ctx,
+	scopes := cfg.OktaScopes
+	if len(scopes) == 0 {
+		scopes = config.DefaultOAuthScopes
+	}
+
+	accountTypeRules, err := parseAccountTypeRules(cfg.AccountTypeRules)
+	if err != nil {
+		return nil, err
+	}
+
+	noPasswordRotation := cfg.NoPasswordRotation
+	switch noPasswordRotation {
+	case "":
+		noPasswordRotation = NoPasswordRotationResetEmail
+	case NoPasswordRotationResetEmail, NoPasswordRotationExpire:
+	default:
+		return nil, fmt.Errorf("okta-connector: invalid no-password rotation %q, expected %s or %s",
+			noPasswordRotation, NoPasswordRotationResetEmail, NoPasswordRotationExpire)
+	}
+
+	if cfg.AppUsageLookbackDays < 0 {
+		return nil, fmt.Errorf("okta-connector: invalid app usage lookback of %d days, expected 0 or more", cfg.AppUsageLookbackDays)
+	}
+
+	var authOpts []okta.ConfigSetter
+	switch {
+	case cfg.ApiToken != "":
+		authOpts = append(authOpts, okta.WithToken(cfg.ApiToken))
+	case cfg.OktaClientId != "" && cfg.OktaPrivateKey != "" && cfg.OktaPrivateKeyId != "":
+		authOpts = append(authOpts,
+			okta.WithAuthorizationMode("PrivateKey"),
+			okta.WithClientId(cfg.OktaClientId),
+			okta.WithScopes(scopes),
+			okta.WithPrivateKey(cfg.OktaPrivateKey),
+			okta.WithPrivateKeyId(cfg.OktaPrivateKeyId),
+		)
+	}
+
+	if len(authOpts) > 0 && cfg.Domain != "" {
+		opts := []okta.ConfigSetter{
 			okta.WithOrgUrl(fmt.Sprintf("https://%s", cfg.Domain)),
-			okta.WithToken(cfg.ApiToken),
 			okta.WithHttpClientPtr(client),
 			okta.WithCache(cfg.Cache),
 			okta.WithCacheTti(cfg.CacheTTI),
 			okta.WithCacheTtl(cfg.CacheTTL),
-		)
+		}
+		_, oktaClient, err = okta.NewClient(ctx, append(opts, authOpts...)...)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return &Okta{
+	o := &Okta{
 		client:              oktaClient,
 		domain:              cfg.Domain,
 		apiToken:            cfg.ApiToken,
+		clientId:            cfg.OktaClientId,
+		scopes:              scopes,
 		skipSecondaryEmails: cfg.SkipSecondaryEmails,
+		syncInactiveApps:    cfg.SyncInactiveApps,
+		appGroupPriority:    cfg.AppGroupPriority,
+		syncCustomRoles:     cfg.SyncCustomRoles,
+
+		useAdministratorsEndpoint: cfg.UseAdministratorsEndpoint,
+		accountTypeRules:          accountTypeRules,
+		syncIdentityProviders:     cfg.SyncIdentityProviders,
+		syncAuthenticators:        cfg.SyncAuthenticators,
+		deactivateOnlyOnDelete:    cfg.DeactivateOnlyOnDelete,
+		noPasswordRotation:        noPasswordRotation,
+		appUsageLookback:          time.Duration(cfg.AppUsageLookbackDays) * 24 * time.Hour,
 		ciamConfig: &ciamConfig{
 			EmailDomains: cfg.CiamEmailDomains,
 		},
-	}, nil
+	}
+
+	o.eventFeeds, err = newEventFeeds(o, cfg.EventFilterGroups, cfg.ExtraEventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	o.actionManager, err = newActionManager(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
 }
 
 type AppUserSchema struct {
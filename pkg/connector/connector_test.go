@@ -0,0 +1,99 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/conductorone/baton-okta-ciam/pkg/config"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient returns an okta client for a fake Okta API served by handler. The response cache is enabled, as it
+// is by default, unless opts turn it off.
+func newTestClient(t *testing.T, handler http.Handler, opts ...okta.ConfigSetter) *okta.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	opts = append([]okta.ConfigSetter{
+		okta.WithOrgUrl(server.URL),
+		okta.WithToken("test-token"),
+		okta.WithTestingDisableHttpsCheck(true),
+		okta.WithRequestTimeout(5),
+		okta.WithRateLimitMaxRetries(0),
+	}, opts...)
+	_, client, err := okta.NewClient(context.Background(), opts...)
+	require.NoError(t, err)
+
+	return client
+}
+
+func Test_missingScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		granted  []string
+		required []string
+		want     []string
+	}{
+		{
+			name:     "all granted",
+			granted:  []string{"okta.users.read", "okta.roles.read", "okta.users.manage"},
+			required: []string{"okta.users.read", "okta.roles.read"},
+			want:     nil,
+		},
+		{
+			name:     "some missing",
+			granted:  []string{"okta.users.read"},
+			required: []string{"okta.users.read", "okta.roles.read", "okta.logs.read"},
+			want:     []string{"okta.roles.read", "okta.logs.read"},
+		},
+		{
+			name:     "nothing granted",
+			granted:  nil,
+			required: []string{"okta.users.read"},
+			want:     []string{"okta.users.read"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, missingScopes(tt.granted, tt.required))
+		})
+	}
+}
+
+func Test_NewDefaultScopes(t *testing.T) {
+	o, err := New(context.Background(), &Config{})
+	require.NoError(t, err)
+	require.Equal(t, config.DefaultOAuthScopes, o.scopes)
+}
+
+func Test_ListResourceTypes(t *testing.T) {
+	ctx := context.Background()
+	o := &Okta{ciamConfig: &ciamConfig{}}
+
+	resourceTypeIDs := func() []string {
+		resp, err := o.ListResourceTypes(ctx, nil)
+		require.NoError(t, err)
+		var ids []string
+		for _, resourceType := range resp.List {
+			ids = append(ids, resourceType.Id)
+		}
+		return ids
+	}
+
+	require.Equal(t, []string{"user", "user-type", "group", "app", "role"}, resourceTypeIDs())
+
+	o.syncIdentityProviders = true
+	o.syncAuthenticators = true
+	o.syncCustomRoles = true
+	require.Equal(t, []string{
+		"user", "user-type", "group", "app", "role",
+		resourceTypeIdentityProvider.Id, resourceTypeAuthenticator.Id,
+		resourceTypeCustomRole.Id, resourceTypeResourceSet.Id, resourceTypeResourceSetBinding.Id,
+	}, resourceTypeIDs())
+}
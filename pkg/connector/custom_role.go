@@ -0,0 +1,242 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	sdkEntitlement "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	sdkResource "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"go.uber.org/zap"
+)
+
+type customRoleResourceType struct {
+	resourceType *v2.ResourceType
+	connector    *Okta
+}
+
+func (o *customRoleResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return o.resourceType
+}
+
+func (o *customRoleResourceType) List(
+	ctx context.Context,
+	resourceID *v2.ResourceId,
+	token *pagination.Token,
+) ([]*v2.Resource, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	bag, page, err := parsePageToken(token.Token, &v2.ResourceId{ResourceType: resourceTypeCustomRole.Id})
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse page token: %w", err)
+	}
+
+	roles, nextPage, respCtx, err := listIamCustomRoles(ctx, o.connector.client, token, page)
+	if err != nil {
+		if errors.Is(err, errMissingRolePermissions) {
+			l.Warn("okta-connectorv2: missing role permissions")
+			return nil, "", nil, nil
+		}
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list custom roles: %w", err)
+	}
+
+	_, annos, err := parseResp(respCtx.OktaResponse)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	err = bag.Next(nextPage)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to fetch bag.Next: %w", err)
+	}
+
+	var rv []*v2.Resource
+	for _, role := range roles {
+		permissions, err := listIamCustomRolePermissions(ctx, o.connector.client, role.ID)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list custom role permissions: %w", err)
+		}
+
+		resource, err := customRoleResource(ctx, role, permissions)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to create custom role resource: %w", err)
+		}
+
+		rv = append(rv, resource)
+	}
+
+	pageToken, err := bag.Marshal()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, pageToken, annos, nil
+}
+
+func (o *customRoleResourceType) Entitlements(
+	_ context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	description := fmt.Sprintf("Has the %s custom role in Okta", resource.DisplayName)
+	permissions := customRolePermissionsFromResource(resource)
+	if len(permissions) > 0 {
+		description = fmt.Sprintf("%s, granting the permissions: %s", description, strings.Join(permissions, ", "))
+	}
+	// Custom roles are only assigned together with a resource set, so this entitlement is sync-only.
+	description += ". Grant the member entitlement of a resource set binding to assign it"
+
+	en := sdkEntitlement.NewAssignmentEntitlement(resource, "assigned",
+		sdkEntitlement.WithDisplayName(fmt.Sprintf("%s Role Member", resource.DisplayName)),
+		sdkEntitlement.WithDescription(description),
+		sdkEntitlement.WithAnnotation(&v2.V1Identifier{
+			Id: V1MembershipEntitlementID(resource.Id.GetResource()),
+		}),
+	)
+
+	return []*v2.Entitlement{en}, "", nil, nil
+}
+
+// Custom role grants are made by the role syncer for users and the group syncer for groups, which read the roles of
+// every assignee once instead of once per custom role.
+func (o *customRoleResourceType) Grants(
+	_ context.Context,
+	_ *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func (o *customRoleResourceType) Get(ctx context.Context, resourceId *v2.ResourceId, parentResourceId *v2.ResourceId) (*v2.Resource, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	l.Debug("getting custom role", zap.String("role_id", resourceId.Resource))
+
+	role, err := getIamCustomRole(ctx, o.connector.client, resourceId.Resource)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connectorv2: failed to find custom role: %w", err)
+	}
+
+	permissions, err := listIamCustomRolePermissions(ctx, o.connector.client, role.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connectorv2: failed to list custom role permissions: %w", err)
+	}
+
+	resource, err := customRoleResource(ctx, role, permissions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resource, nil, nil
+}
+
+func listIamCustomRoles(
+	ctx context.Context,
+	client *okta.Client,
+	token *pagination.Token,
+	after string,
+) ([]*CustomRole, string, *responseContext, error) {
+	reqUrl := apiPathListIamCustomRoles + queryParams(token.Size, after).String()
+
+	var data CustomRolesAPIData
+	resp, err := doRequest(ctx, client, http.MethodGet, reqUrl, nil, &data)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusForbidden {
+			return nil, "", nil, errMissingRolePermissions
+		}
+		return nil, "", nil, handleOktaResponseError(resp, err)
+	}
+
+	nextPage, err := nextPageFromLink(data.Links)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return data.Roles, nextPage, &responseContext{OktaResponse: resp}, nil
+}
+
+func getIamCustomRole(ctx context.Context, client *okta.Client, roleID string) (*CustomRole, error) {
+	reqUrl, err := url.JoinPath(apiPathListIamCustomRoles, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &CustomRole{}
+	resp, err := doRequest(ctx, client, http.MethodGet, reqUrl, nil, role)
+	if err != nil {
+		return nil, handleOktaResponseError(resp, err)
+	}
+
+	return role, nil
+}
+
+func listIamCustomRolePermissions(ctx context.Context, client *okta.Client, roleID string) ([]*Permission, error) {
+	reqUrl, err := url.JoinPath(apiPathListIamCustomRoles, roleID, "permissions")
+	if err != nil {
+		return nil, err
+	}
+
+	var data PermissionsAPIData
+	resp, err := doRequest(ctx, client, http.MethodGet, reqUrl, nil, &data)
+	if err != nil {
+		return nil, handleOktaResponseError(resp, err)
+	}
+
+	return data.Permissions, nil
+}
+
+// Create a new connector resource for an okta custom admin role.
+func customRoleResource(ctx context.Context, role *CustomRole, permissions []*Permission) (*v2.Resource, error) {
+	permissionLabels := make([]interface{}, 0, len(permissions))
+	for _, permission := range permissions {
+		permissionLabels = append(permissionLabels, permission.Label)
+	}
+
+	profile := map[string]interface{}{
+		"id":          role.ID,
+		"label":       role.Label,
+		"description": role.Description,
+		"type":        roleTypeCustom,
+		"permissions": permissionLabels,
+	}
+
+	return sdkResource.NewRoleResource(
+		role.Label,
+		resourceTypeCustomRole,
+		role.ID,
+		[]sdkResource.RoleTraitOption{sdkResource.WithRoleProfile(profile)},
+		sdkResource.WithAnnotation(&v2.V1Identifier{
+			Id: fmtResourceIdV1(role.ID),
+		}),
+		sdkResource.WithDescription(role.Description),
+	)
+}
+
+func customRolePermissionsFromResource(resource *v2.Resource) []string {
+	roleTrait, err := sdkResource.GetRoleTrait(resource)
+	if err != nil {
+		return nil
+	}
+
+	var permissions []string
+	for _, value := range roleTrait.GetProfile().GetFields()["permissions"].GetListValue().GetValues() {
+		if permission := value.GetStringValue(); permission != "" {
+			permissions = append(permissions, permission)
+		}
+	}
+
+	return permissions
+}
+
+func customRoleBuilder(connector *Okta) *customRoleResourceType {
+	return &customRoleResourceType{
+		resourceType: resourceTypeCustomRole,
+		connector:    connector,
+	}
+}
@@ -0,0 +1,47 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_customRoleResource(t *testing.T) {
+	role := &CustomRole{
+		ID:          "cr0kuwv5507zJCtSy697",
+		Label:       "Customer Support",
+		Description: "Support desk for customers",
+	}
+	permissions := []*Permission{
+		{Label: "okta.users.read"},
+		{Label: "okta.users.lifecycle.manage"},
+	}
+
+	resource, err := customRoleResource(context.Background(), role, permissions)
+	require.NoError(t, err)
+	require.Equal(t, resourceTypeCustomRole.Id, resource.Id.ResourceType)
+	require.Equal(t, role.ID, resource.Id.Resource)
+	require.Equal(t, []string{"okta.users.read", "okta.users.lifecycle.manage"}, customRolePermissionsFromResource(resource))
+}
+
+func Test_userRoleGrantsCustomRoles(t *testing.T) {
+	o := &ciamResourceBuilder{connector: &Okta{syncCustomRoles: true, useAdministratorsEndpoint: true}}
+	roles := []*Roles{
+		{Type: "SUPER_ADMIN", AssignmentType: roleAssignmentTypeUser, Status: userStatusActive},
+		{Id: "ra1", Type: roleTypeCustom, Role: "cr0a", ResourceSet: "iam1", AssignmentType: roleAssignmentTypeUser, Status: userStatusActive},
+		{Type: roleTypeCustom, Role: "cr0b", ResourceSet: "iam1", AssignmentType: roleAssignmentTypeUser, Status: "INACTIVE"},
+		{Type: roleTypeCustom, Role: "cr0d", ResourceSet: "iam1", AssignmentType: roleAssignmentTypeGroup, Status: userStatusActive},
+	}
+
+	grants, err := o.userRoleGrants(context.Background(), "00u1", roles)
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	require.Equal(t, "custom-role:cr0a:assigned", grants[0].Entitlement.Id)
+	require.Equal(t, "00u1", grants[0].Principal.Id.Resource)
+
+	o.connector.syncCustomRoles = false
+	grants, err = o.userRoleGrants(context.Background(), "00u1", roles)
+	require.NoError(t, err)
+	require.Empty(t, grants)
+}
@@ -0,0 +1,101 @@
+package connector
+
+import (
+	"context"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+)
+
+const (
+	eventFeedUserLifecycle      = "okta_user_lifecycle"
+	eventFeedAdminPrivileges    = "okta_admin_privileges"
+	eventFeedSecuritySignals    = "okta_security_signals"
+	eventFeedUserAuthentication = "okta_user_authentication"
+)
+
+// oktaEventFeed is a feed of System Log events. Every feed has its own filter expression and cursor, so frequent
+// events in one feed don't hold back the events of the others.
+type oktaEventFeed struct {
+	connector  *Okta
+	id         string
+	eventTypes []v2.EventType
+	filters    []EventFilter
+}
+
+func (feed *oktaEventFeed) EventFeedMetadata(ctx context.Context) *v2.EventFeedMetadata {
+	return &v2.EventFeedMetadata{
+		Id:                  feed.id,
+		SupportedEventTypes: feed.eventTypes,
+	}
+}
+
+// The filter groups of every event feed. Grants and revokes have no event type of their own, so feeds emitting them
+// also support the unspecified type.
+var eventFeedGroups = []struct {
+	id         string
+	eventTypes []v2.EventType
+	groups     []string
+}{
+	{
+		id:         eventFeedUserLifecycle,
+		eventTypes: []v2.EventType{v2.EventType_EVENT_TYPE_RESOURCE_CHANGE, v2.EventType_EVENT_TYPE_UNSPECIFIED},
+		groups:     []string{EventFilterGroupLifecycle, EventFilterGroupGroupMembership},
+	},
+	{
+		id:         eventFeedAdminPrivileges,
+		eventTypes: []v2.EventType{v2.EventType_EVENT_TYPE_UNSPECIFIED},
+		groups:     []string{EventFilterGroupPrivilege},
+	},
+	{
+		id:         eventFeedSecuritySignals,
+		eventTypes: []v2.EventType{v2.EventType_EVENT_TYPE_RESOURCE_CHANGE},
+		groups:     []string{EventFilterGroupSecurity},
+	},
+	{
+		id:         eventFeedUserAuthentication,
+		eventTypes: []v2.EventType{v2.EventType_EVENT_TYPE_RESOURCE_CHANGE, v2.EventType_EVENT_TYPE_USAGE},
+		groups:     []string{EventFilterGroupAuthentication, EventFilterGroupAppUsage},
+	},
+}
+
+// newEventFeeds creates a feed for every feed with an enabled filter group. Extra event types are part of the user
+// lifecycle feed.
+func newEventFeeds(connector *Okta, groups []string, extraEventTypes []string) ([]*oktaEventFeed, error) {
+	filtersByGroup, extraFilter, err := newEventFilters(groups, extraEventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	var rv []*oktaEventFeed
+	for _, feedGroups := range eventFeedGroups {
+		var filters []EventFilter
+		for _, group := range feedGroups.groups {
+			filters = append(filters, filtersByGroup[group]...)
+		}
+		if feedGroups.id == eventFeedUserLifecycle && extraFilter != nil {
+			filters = append(filters, *extraFilter)
+		}
+		if len(filters) == 0 {
+			continue
+		}
+
+		rv = append(rv, &oktaEventFeed{
+			connector:  connector,
+			id:         feedGroups.id,
+			eventTypes: feedGroups.eventTypes,
+			filters:    filters,
+		})
+	}
+
+	return rv, nil
+}
+
+func (o *Okta) EventFeeds(ctx context.Context) []connectorbuilder.EventFeed {
+	rv := make([]connectorbuilder.EventFeed, 0, len(o.eventFeeds))
+	for _, feed := range o.eventFeeds {
+		rv = append(rv, feed)
+	}
+
+	return rv
+}
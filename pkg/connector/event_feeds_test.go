@@ -0,0 +1,43 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newEventFeeds(t *testing.T) {
+	ctx := context.Background()
+	o := &Okta{}
+
+	feeds, err := newEventFeeds(o, nil, nil)
+	require.NoError(t, err)
+
+	var ids []string
+	for _, feed := range feeds {
+		metadata := feed.EventFeedMetadata(ctx)
+		require.NoError(t, metadata.Validate())
+		ids = append(ids, metadata.Id)
+		require.Same(t, o, feed.connector)
+	}
+	require.Equal(t, []string{eventFeedUserLifecycle, eventFeedAdminPrivileges, eventFeedSecuritySignals}, ids)
+	require.Len(t, feeds[0].filters, 3)
+
+	feeds, err = newEventFeeds(o, []string{EventFilterGroupAuthentication}, []string{"user.mfa.factor.unenroll"})
+	require.NoError(t, err)
+	require.Len(t, feeds, 2)
+	require.Equal(t, eventFeedUserLifecycle, feeds[0].id)
+	require.Len(t, feeds[0].filters, 1)
+	require.Equal(t, eventFeedUserAuthentication, feeds[1].id)
+
+	feeds, err = newEventFeeds(o, []string{EventFilterGroupAppUsage}, nil)
+	require.NoError(t, err)
+	require.Len(t, feeds, 1)
+	require.Equal(t, eventFeedUserAuthentication, feeds[0].id)
+	require.Contains(t, feeds[0].EventFeedMetadata(ctx).SupportedEventTypes, v2.EventType_EVENT_TYPE_USAGE)
+
+	o.eventFeeds = feeds
+	require.Len(t, o.EventFeeds(ctx), 1)
+}
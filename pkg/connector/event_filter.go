@@ -21,7 +21,8 @@ type EventFilter struct {
 	// May contain additional targets.
 	TargetTypes mapset.Set[string]
 	// Required, will be called for each event that matches the filter.
-	EventHandler func(*oktaSDK.LogEvent, map[string][]*oktaSDK.LogTarget, *v2.Event) error
+	// May return no events, or several when the log event covers more than one change.
+	EventHandler func(*oktaSDK.LogEvent, map[string][]*oktaSDK.LogTarget) ([]*v2.Event, error)
 }
 
 func filterJoiner(joiner string, filters ...string) string {
@@ -50,8 +51,10 @@ func (filter *EventFilter) Filter() string {
 	}
 
 	targetFilters := []string{}
-	for _, targetType := range filter.TargetTypes.ToSlice() {
-		targetFilters = append(targetFilters, filterMaker("target.type", targetType))
+	if filter.TargetTypes != nil {
+		for _, targetType := range filter.TargetTypes.ToSlice() {
+			targetFilters = append(targetFilters, filterMaker("target.type", targetType))
+		}
 	}
 	targetFilter := filterJoiner(" and ", targetFilters...)
 
@@ -77,7 +80,7 @@ func (filter *EventFilter) Matches(event *oktaSDK.LogEvent) bool {
 	}
 
 	// if we have target types, is at least one of the target types in our set?
-	if filter.TargetTypes.Cardinality() > 0 {
+	if filter.TargetTypes != nil && filter.TargetTypes.Cardinality() > 0 {
 		targetSet := mapset.NewSet[string]()
 		for _, target := range event.Target {
 			targetSet.Add(target.Type)
@@ -91,21 +94,20 @@ func (filter *EventFilter) Matches(event *oktaSDK.LogEvent) bool {
 	return true
 }
 
-func (filter *EventFilter) Handle(event *oktaSDK.LogEvent) (*v2.Event, error) {
+func (filter *EventFilter) Handle(event *oktaSDK.LogEvent) ([]*v2.Event, error) {
 	targetMap := make(map[string][]*oktaSDK.LogTarget)
 	for _, target := range event.Target {
 		targetMap[target.Type] = append(targetMap[target.Type], target)
 	}
 
-	rv := &v2.Event{
+	return filter.EventHandler(event, targetMap)
+}
+
+// newEvent creates an event with the id and time of the log event. Handlers returning several events for the same
+// log event give each of them a distinct id.
+func newEvent(event *oktaSDK.LogEvent) *v2.Event {
+	return &v2.Event{
 		Id:         event.Uuid,
 		OccurredAt: timestamppb.New(*event.Published),
 	}
-
-	err := filter.EventHandler(event, targetMap, rv)
-	if err != nil {
-		return nil, err
-	}
-
-	return rv, nil
 }
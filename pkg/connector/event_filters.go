@@ -2,30 +2,448 @@ package connector
 
 import (
 	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
 	mapset "github.com/deckarep/golang-set/v2"
 	oktaSDK "github.com/okta/okta-sdk-golang/v2/okta"
 )
 
 var (
 	UserLifecycleFilter = EventFilter{
-		EventTypes:  mapset.NewSet[string]("user.lifecycle.create", "user.lifecycle.activate", "user.account.update_profile"),
+		EventTypes:   mapset.NewSet[string]("user.lifecycle.create", "user.lifecycle.activate", "user.account.update_profile"),
+		TargetTypes:  mapset.NewSet[string]("User"),
+		EventHandler: userChangeEventHandler,
+	}
+
+	// Changes to the status of a user. Deleted users are annotated with a deleted user resource, since getting the
+	// resource of a deleted user fails.
+	UserStatusFilter = EventFilter{
+		EventTypes: mapset.NewSet[string](
+			"user.lifecycle.deactivate",
+			"user.lifecycle.suspend",
+			"user.lifecycle.unsuspend",
+			"user.lifecycle.reactivate",
+			eventTypeUserDeleteInitiated,
+			eventTypeUserDeleteCompleted,
+		),
 		TargetTypes: mapset.NewSet[string]("User"),
-		EventHandler: func(event *oktaSDK.LogEvent, targetMap map[string][]*oktaSDK.LogTarget, rv *v2.Event) error {
-			if len(targetMap["User"]) != 1 {
-				return fmt.Errorf("okta-connectorv2: expected 1 User target, got %d", len(targetMap["User"]))
+		EventHandler: func(event *oktaSDK.LogEvent, targetMap map[string][]*oktaSDK.LogTarget) ([]*v2.Event, error) {
+			if logEventFailed(event) {
+				return nil, nil
+			}
+
+			rv, err := userChangeEvent(event, targetMap)
+			if err != nil {
+				return nil, err
+			}
+
+			if event.EventType == eventTypeUserDeleteInitiated || event.EventType == eventTypeUserDeleteCompleted {
+				var annos annotations.Annotations
+				annos.Update(deletedUserResource(rv.GetResourceChangeEvent().GetResourceId()))
+				rv.Annotations = annos
+			}
+
+			return []*v2.Event{rv}, nil
+		},
+	}
+
+	// Users added to or removed from groups.
+	GroupMembershipFilter = EventFilter{
+		EventTypes:  mapset.NewSet[string](eventTypeGroupMembershipAdd, eventTypeGroupMembershipRemove),
+		TargetTypes: mapset.NewSet[string]("User", "UserGroup"),
+		EventHandler: func(event *oktaSDK.LogEvent, targetMap map[string][]*oktaSDK.LogTarget) ([]*v2.Event, error) {
+			if logEventFailed(event) {
+				return nil, nil
+			}
+			if len(targetMap["User"]) != 1 || len(targetMap["UserGroup"]) != 1 {
+				return nil, fmt.Errorf("okta-connectorv2: expected 1 User and 1 UserGroup target, got %d and %d",
+					len(targetMap["User"]), len(targetMap["UserGroup"]))
+			}
+
+			groupResource := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeGroup.Id, Resource: targetMap["UserGroup"][0].Id}}
+			grant := groupGrant(groupResource, targetMap["User"][0].Id)
+
+			rv := newEvent(event)
+			if event.EventType == eventTypeGroupMembershipAdd {
+				rv.Event = &v2.Event_GrantEvent{
+					GrantEvent: &v2.GrantEvent{Grant: grant},
+				}
+			} else {
+				rv.Event = &v2.Event_RevokeEvent{
+					RevokeEvent: &v2.RevokeEvent{
+						Entitlement: grant.Entitlement,
+						Principal:   grant.Principal,
+					},
+				}
+			}
+			return []*v2.Event{rv}, nil
+		},
+	}
+
+	// Lockouts, threat detections and MFA resets, reported as changes to the users they concern. Threat detections
+	// that don't target a user are skipped.
+	SecuritySignalFilter = EventFilter{
+		EventTypes: mapset.NewSet[string](
+			"user.account.lock",
+			"user.account.lock.limit",
+			"user.account.unlock",
+			"user.account.unlock_by_admin",
+			"security.threat.detected",
+			"user.risk.detect",
+			"user.risk.change",
+			"user.mfa.factor.reset_all",
+			"user.mfa.factor.deactivate",
+		),
+		EventHandler: func(event *oktaSDK.LogEvent, targetMap map[string][]*oktaSDK.LogTarget) ([]*v2.Event, error) {
+			if logEventFailed(event) {
+				return nil, nil
+			}
+
+			users := targetMap["User"]
+			rv := make([]*v2.Event, 0, len(users))
+			for _, user := range users {
+				ev := newEvent(event)
+				if len(users) > 1 {
+					ev.Id = fmt.Sprintf("%s:%s", event.Uuid, user.Id)
+				}
+				ev.Event = &v2.Event_ResourceChangeEvent{
+					ResourceChangeEvent: &v2.ResourceChangeEvent{
+						ResourceId: &v2.ResourceId{
+							ResourceType: resourceTypeUser.Id,
+							Resource:     user.Id,
+						},
+					},
+				}
+				rv = append(rv, ev)
+			}
+			return rv, nil
+		},
+	}
+
+	// Users signing in, which changes their last login.
+	UserAuthenticationFilter = EventFilter{
+		EventTypes: mapset.NewSet[string](eventTypeUserSessionStart),
+		ActorType:  "User",
+		EventHandler: func(event *oktaSDK.LogEvent, targetMap map[string][]*oktaSDK.LogTarget) ([]*v2.Event, error) {
+			if logEventFailed(event) {
+				return nil, nil
+			}
+			if event.Actor == nil || event.Actor.Id == "" {
+				return nil, fmt.Errorf("okta-connectorv2: expected a User actor")
 			}
-			user := targetMap["User"][0]
+
+			rv := newEvent(event)
 			rv.Event = &v2.Event_ResourceChangeEvent{
 				ResourceChangeEvent: &v2.ResourceChangeEvent{
 					ResourceId: &v2.ResourceId{
 						ResourceType: resourceTypeUser.Id,
-						Resource:     user.Id,
+						Resource:     event.Actor.Id,
 					},
 				},
 			}
-			return nil
+			return []*v2.Event{rv}, nil
+		},
+	}
+
+	// Users signing in to apps, reported as usage of the app by the user.
+	AppUsageFilter = EventFilter{
+		EventTypes:  mapset.NewSet[string](eventTypeUserAuthenticationSSO, eventTypeUserSessionStart),
+		ActorType:   "User",
+		TargetTypes: mapset.NewSet[string](logTargetAppInstance),
+		EventHandler: func(event *oktaSDK.LogEvent, targetMap map[string][]*oktaSDK.LogTarget) ([]*v2.Event, error) {
+			if logEventFailed(event) {
+				return nil, nil
+			}
+			if event.Actor == nil || event.Actor.Id == "" {
+				return nil, fmt.Errorf("okta-connectorv2: expected a User actor")
+			}
+
+			user := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: event.Actor.Id}}
+			apps := targetMap[logTargetAppInstance]
+			rv := make([]*v2.Event, 0, len(apps))
+			for _, app := range apps {
+				// The same sign in can also be reported by the authentication filter, so usage events get their own id.
+				ev := newEvent(event)
+				ev.Id = fmt.Sprintf("%s:app:%s", event.Uuid, app.Id)
+				ev.Event = &v2.Event_UsageEvent{
+					UsageEvent: &v2.UsageEvent{
+						TargetResource: &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeApp.Id, Resource: app.Id}},
+						ActorResource:  user,
+					},
+				}
+				rv = append(rv, ev)
+			}
+			return rv, nil
+		},
+	}
+
+	// Admin roles granted to or revoked from a user directly in Okta.
+	UserPrivilegeFilter = EventFilter{
+		EventTypes:  mapset.NewSet[string](eventTypeUserPrivilegeGrant, eventTypeUserPrivilegeRevoke),
+		TargetTypes: mapset.NewSet[string]("User"),
+		EventHandler: func(event *oktaSDK.LogEvent, targetMap map[string][]*oktaSDK.LogTarget) ([]*v2.Event, error) {
+			if len(targetMap["User"]) != 1 {
+				return nil, fmt.Errorf("okta-connectorv2: expected 1 User target, got %d", len(targetMap["User"]))
+			}
+			principal := &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: targetMap["User"][0].Id}
+			return privilegeEvents(event, principal, event.EventType == eventTypeUserPrivilegeGrant), nil
+		},
+	}
+
+	// Admin roles granted to or revoked from a group, which its members hold through the group.
+	GroupPrivilegeFilter = EventFilter{
+		EventTypes:  mapset.NewSet[string](eventTypeGroupPrivilegeGrant, eventTypeGroupPrivilegeRevoke),
+		TargetTypes: mapset.NewSet[string]("UserGroup"),
+		EventHandler: func(event *oktaSDK.LogEvent, targetMap map[string][]*oktaSDK.LogTarget) ([]*v2.Event, error) {
+			if len(targetMap["UserGroup"]) != 1 {
+				return nil, fmt.Errorf("okta-connectorv2: expected 1 UserGroup target, got %d", len(targetMap["UserGroup"]))
+			}
+			principal := &v2.ResourceId{ResourceType: resourceTypeGroup.Id, Resource: targetMap["UserGroup"][0].Id}
+			return privilegeEvents(event, principal, event.EventType == eventTypeGroupPrivilegeGrant), nil
 		},
 	}
 )
+
+const (
+	eventTypeUserPrivilegeGrant   = "user.account.privilege.grant"
+	eventTypeUserPrivilegeRevoke  = "user.account.privilege.revoke"
+	eventTypeGroupPrivilegeGrant  = "group.privilege.grant"
+	eventTypeGroupPrivilegeRevoke = "group.privilege.revoke"
+
+	eventTypeUserAuthenticationSSO = "user.authentication.sso"
+	eventTypeUserSessionStart      = "user.session.start"
+	eventTypeGroupMembershipAdd    = "group.user_membership.add"
+	eventTypeGroupMembershipRemove = "group.user_membership.remove"
+	eventTypeUserDeleteInitiated   = "user.lifecycle.delete.initiated"
+	eventTypeUserDeleteCompleted   = "user.lifecycle.delete.completed"
+
+	logOutcomeSuccess    = "SUCCESS"
+	logTargetAppInstance = "AppInstance"
+)
+
+// userChangeEventHandler reports a change to the single user targeted by a log event.
+func userChangeEventHandler(event *oktaSDK.LogEvent, targetMap map[string][]*oktaSDK.LogTarget) ([]*v2.Event, error) {
+	rv, err := userChangeEvent(event, targetMap)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*v2.Event{rv}, nil
+}
+
+// userChangeEvent creates a change event for the single user targeted by a log event.
+func userChangeEvent(event *oktaSDK.LogEvent, targetMap map[string][]*oktaSDK.LogTarget) (*v2.Event, error) {
+	if len(targetMap["User"]) != 1 {
+		return nil, fmt.Errorf("okta-connectorv2: expected 1 User target, got %d", len(targetMap["User"]))
+	}
+	user := targetMap["User"][0]
+
+	rv := newEvent(event)
+	rv.Event = &v2.Event_ResourceChangeEvent{
+		ResourceChangeEvent: &v2.ResourceChangeEvent{
+			ResourceId: &v2.ResourceId{
+				ResourceType: resourceTypeUser.Id,
+				Resource:     user.Id,
+			},
+		},
+	}
+
+	return rv, nil
+}
+
+// deletedUserResource is the resource of a deleted user, which only has its id and the deleted status.
+func deletedUserResource(resourceID *v2.ResourceId) *v2.Resource {
+	var annos annotations.Annotations
+	annos.Update(&v2.UserTrait{
+		Status: &v2.UserTrait_Status{
+			Status:  v2.UserTrait_Status_STATUS_DELETED,
+			Details: "DELETED",
+		},
+	})
+
+	return &v2.Resource{
+		Id:          resourceID,
+		Annotations: annos,
+	}
+}
+
+// logEventFailed reports whether the change of a log event didn't happen.
+func logEventFailed(event *oktaSDK.LogEvent) bool {
+	return event.Outcome != nil && event.Outcome.Result != "" && event.Outcome.Result != logOutcomeSuccess
+}
+
+// privilegeEvents creates a grant or revoke event on the assigned entitlement of every standard role named in a
+// privilege log event. Custom roles and failed changes are skipped.
+func privilegeEvents(event *oktaSDK.LogEvent, principal *v2.ResourceId, granted bool) []*v2.Event {
+	if logEventFailed(event) {
+		return nil
+	}
+
+	debugKey := "privilegeRevoked"
+	if granted {
+		debugKey = "privilegeGranted"
+	}
+
+	var rv []*v2.Event
+	for _, roleType := range privilegeRoleTypes(logDebugData(event, debugKey)) {
+		roleResource := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeRole.Id, Resource: roleType}}
+
+		var grant *v2.Grant
+		if principal.ResourceType == resourceTypeGroup.Id {
+			grant = roleGroupGrant(principal.Resource, roleResource)
+		} else {
+			grant = roleGrant(principal.Resource, roleResource)
+		}
+
+		// One log event can change several roles, each event needs its own id.
+		ev := newEvent(event)
+		ev.Id = fmt.Sprintf("%s:%s", event.Uuid, roleType)
+		if granted {
+			ev.Event = &v2.Event_GrantEvent{
+				GrantEvent: &v2.GrantEvent{Grant: grant},
+			}
+		} else {
+			ev.Event = &v2.Event_RevokeEvent{
+				RevokeEvent: &v2.RevokeEvent{
+					Entitlement: grant.Entitlement,
+					Principal:   grant.Principal,
+				},
+			}
+		}
+		rv = append(rv, ev)
+	}
+
+	return rv
+}
+
+// logDebugData returns a string value of the debug data of a log event.
+func logDebugData(event *oktaSDK.LogEvent, key string) string {
+	if event.DebugContext == nil {
+		return ""
+	}
+
+	debugData, ok := event.DebugContext.DebugData.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	value, _ := debugData[key].(string)
+	return value
+}
+
+var (
+	privilegeScopeSuffix = regexp.MustCompile(`\s*\(.*\)$`)
+	nonAlphanumeric      = regexp.MustCompile(`[^a-z0-9]`)
+)
+
+// The system log names some roles differently from their labels.
+var privilegeRoleAliases = map[string]string{
+	"organizationadministrator": "ORG_ADMIN",
+}
+
+// privilegeRoleTypes returns the standard role types in the comma separated privileges of a log event. The system
+// log names roles by their label, in its own capitalization and sometimes followed by a scope, e.g.
+// "Help desk administrator (all groups)", so names are compared without case, punctuation and scope.
+func privilegeRoleTypes(privileges string) []string {
+	normalize := func(name string) string {
+		name = privilegeScopeSuffix.ReplaceAllString(strings.TrimSpace(name), "")
+		return nonAlphanumeric.ReplaceAllString(strings.ToLower(name), "")
+	}
+
+	var rv []string
+	for _, privilege := range strings.Split(privileges, ",") {
+		name := normalize(privilege)
+		if name == "" {
+			continue
+		}
+
+		roleType, ok := privilegeRoleAliases[name]
+		if !ok {
+			for _, role := range standardRoleTypes {
+				if normalize(role.Label) == name || normalize(role.Type) == name {
+					roleType = role.Type
+					break
+				}
+			}
+		}
+		if roleType != "" && !slices.Contains(rv, roleType) {
+			rv = append(rv, roleType)
+		}
+	}
+
+	return rv
+}
+
+// Names of the groups of event filters that can be enabled.
+const (
+	EventFilterGroupLifecycle       = "lifecycle"
+	EventFilterGroupPrivilege       = "privilege"
+	EventFilterGroupGroupMembership = "group-membership"
+	EventFilterGroupAuthentication  = "authentication"
+	EventFilterGroupSecurity        = "security"
+	EventFilterGroupAppUsage        = "app-usage"
+)
+
+var eventFilterGroups = map[string][]EventFilter{
+	EventFilterGroupLifecycle:       {UserLifecycleFilter, UserStatusFilter},
+	EventFilterGroupPrivilege:       {UserPrivilegeFilter, GroupPrivilegeFilter},
+	EventFilterGroupGroupMembership: {GroupMembershipFilter},
+	EventFilterGroupAuthentication:  {UserAuthenticationFilter},
+	EventFilterGroupSecurity:        {SecuritySignalFilter},
+	EventFilterGroupAppUsage:        {AppUsageFilter},
+}
+
+// DefaultEventFilterGroups are the filter groups used when none are configured. Authentication and app usage are left
+// out, since sign ins are by far the most frequent events.
+var DefaultEventFilterGroups = []string{
+	EventFilterGroupLifecycle,
+	EventFilterGroupPrivilege,
+	EventFilterGroupGroupMembership,
+	EventFilterGroupSecurity,
+}
+
+// newEventFilters returns the filters of every enabled filter group, and a filter reporting changes to the target
+// user of the extra event types that no enabled filter handles, or nil if there are none.
+func newEventFilters(groups []string, extraEventTypes []string) (map[string][]EventFilter, *EventFilter, error) {
+	if len(groups) == 0 {
+		groups = DefaultEventFilterGroups
+	}
+
+	rv := make(map[string][]EventFilter)
+	handled := mapset.NewSet[string]()
+	for _, group := range groups {
+		group = strings.ToLower(strings.TrimSpace(group))
+		filters, ok := eventFilterGroups[group]
+		if !ok {
+			return nil, nil, fmt.Errorf("okta-connector: invalid event filter group %q, expected one of %s, %s, %s, %s, %s or %s",
+				group, EventFilterGroupLifecycle, EventFilterGroupPrivilege, EventFilterGroupGroupMembership,
+				EventFilterGroupSecurity, EventFilterGroupAuthentication, EventFilterGroupAppUsage)
+		}
+
+		rv[group] = filters
+		for _, filter := range filters {
+			handled = handled.Union(filter.EventTypes)
+		}
+	}
+
+	extra := mapset.NewSet[string]()
+	for _, eventType := range extraEventTypes {
+		eventType = strings.TrimSpace(eventType)
+		if eventType != "" && !handled.Contains(eventType) {
+			extra.Add(eventType)
+		}
+	}
+	if extra.Cardinality() == 0 {
+		return rv, nil, nil
+	}
+
+	return rv, &EventFilter{
+		EventTypes:   extra,
+		TargetTypes:  mapset.NewSet[string]("User"),
+		EventHandler: userChangeEventHandler,
+	}, nil
+}
@@ -0,0 +1,232 @@
+package connector
+
+import (
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	oktaSDK "github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/stretchr/testify/require"
+)
+
+func testLogEvent(eventType string, debugData map[string]interface{}, targets ...*oktaSDK.LogTarget) *oktaSDK.LogEvent {
+	published := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return &oktaSDK.LogEvent{
+		Uuid:         "evt1",
+		EventType:    eventType,
+		Published:    &published,
+		Actor:        &oktaSDK.LogActor{Type: "User", Id: "00uadmin"},
+		Outcome:      &oktaSDK.LogOutcome{Result: "SUCCESS"},
+		Target:       targets,
+		DebugContext: &oktaSDK.LogDebugContext{DebugData: debugData},
+	}
+}
+
+func Test_privilegeRoleTypes(t *testing.T) {
+	require.Equal(t, []string{"SUPER_ADMIN", "ORG_ADMIN", "READ_ONLY_ADMIN", "HELP_DESK_ADMIN"},
+		privilegeRoleTypes("Super administrator, Organization administrator, Read only administrator, Help desk administrator (all groups)"))
+	require.Equal(t, []string{"USER_ADMIN"}, privilegeRoleTypes("USER_ADMIN, Group administrator"))
+	require.Empty(t, privilegeRoleTypes("Custom support role"))
+	require.Empty(t, privilegeRoleTypes(""))
+}
+
+func Test_UserPrivilegeFilter(t *testing.T) {
+	user := &oktaSDK.LogTarget{Type: "User", Id: "00u1"}
+
+	event := testLogEvent(eventTypeUserPrivilegeGrant, map[string]interface{}{
+		"privilegeGranted": "Super administrator, Report administrator",
+	}, user)
+	require.True(t, UserPrivilegeFilter.Matches(event))
+
+	events, err := UserPrivilegeFilter.Handle(event)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "evt1:SUPER_ADMIN", events[0].Id)
+
+	grant := events[0].GetGrantEvent().GetGrant()
+	require.NotNil(t, grant)
+	require.Equal(t, "role:SUPER_ADMIN:assigned", grant.Entitlement.Id)
+	require.Equal(t, &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "00u1"}, grant.Principal.Id)
+
+	event = testLogEvent(eventTypeUserPrivilegeRevoke, map[string]interface{}{
+		"privilegeRevoked": "Report administrator",
+	}, user)
+	events, err = UserPrivilegeFilter.Handle(event)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	revoke := events[0].GetRevokeEvent()
+	require.NotNil(t, revoke)
+	require.Equal(t, "role:REPORT_ADMIN:assigned", revoke.Entitlement.Id)
+	require.Equal(t, "00u1", revoke.Principal.Id.Resource)
+
+	event.Outcome.Result = "FAILURE"
+	events, err = UserPrivilegeFilter.Handle(event)
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func Test_GroupPrivilegeFilter(t *testing.T) {
+	event := testLogEvent(eventTypeGroupPrivilegeGrant, map[string]interface{}{
+		"privilegeGranted": "Help desk administrator",
+	}, &oktaSDK.LogTarget{Type: "UserGroup", Id: "00g1"})
+	require.True(t, GroupPrivilegeFilter.Matches(event))
+	require.False(t, UserPrivilegeFilter.Matches(event))
+
+	events, err := GroupPrivilegeFilter.Handle(event)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	grant := events[0].GetGrantEvent().GetGrant()
+	require.Equal(t, "role:HELP_DESK_ADMIN:assigned", grant.Entitlement.Id)
+	require.Equal(t, &v2.ResourceId{ResourceType: resourceTypeGroup.Id, Resource: "00g1"}, grant.Principal.Id)
+}
+
+func Test_UserStatusFilter(t *testing.T) {
+	user := &oktaSDK.LogTarget{Type: "User", Id: "00u1"}
+
+	event := testLogEvent("user.lifecycle.suspend", nil, user)
+	require.True(t, UserStatusFilter.Matches(event))
+	require.False(t, UserLifecycleFilter.Matches(event))
+
+	events, err := UserStatusFilter.Handle(event)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "evt1", events[0].Id)
+	require.Equal(t, &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "00u1"}, events[0].GetResourceChangeEvent().GetResourceId())
+	require.Empty(t, events[0].Annotations)
+
+	events, err = UserStatusFilter.Handle(testLogEvent("user.lifecycle.delete.completed", nil, user))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	deleted := &v2.Resource{}
+	annos := annotations.Annotations(events[0].Annotations)
+	ok, err := annos.Pick(deleted)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "00u1", deleted.Id.Resource)
+
+	userTrait, err := resource.GetUserTrait(deleted)
+	require.NoError(t, err)
+	require.Equal(t, v2.UserTrait_Status_STATUS_DELETED, userTrait.GetStatus().GetStatus())
+
+	event = testLogEvent("user.lifecycle.deactivate", nil, user)
+	event.Outcome.Result = "FAILURE"
+	events, err = UserStatusFilter.Handle(event)
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	_, err = UserStatusFilter.Handle(testLogEvent("user.lifecycle.reactivate", nil))
+	require.Error(t, err)
+}
+
+func Test_GroupMembershipFilter(t *testing.T) {
+	user := &oktaSDK.LogTarget{Type: "User", Id: "00u1"}
+	group := &oktaSDK.LogTarget{Type: "UserGroup", Id: "00g1"}
+
+	events, err := GroupMembershipFilter.Handle(testLogEvent(eventTypeGroupMembershipAdd, nil, user, group))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	grant := events[0].GetGrantEvent().GetGrant()
+	require.Equal(t, "group:00g1:member", grant.Entitlement.Id)
+	require.Equal(t, "00u1", grant.Principal.Id.Resource)
+
+	events, err = GroupMembershipFilter.Handle(testLogEvent(eventTypeGroupMembershipRemove, nil, user, group))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "group:00g1:member", events[0].GetRevokeEvent().GetEntitlement().GetId())
+
+	_, err = GroupMembershipFilter.Handle(testLogEvent(eventTypeGroupMembershipAdd, nil, user))
+	require.Error(t, err)
+}
+
+func Test_UserAuthenticationFilter(t *testing.T) {
+	event := testLogEvent("user.session.start", nil)
+	require.True(t, UserAuthenticationFilter.Matches(event))
+
+	events, err := UserAuthenticationFilter.Handle(event)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "00uadmin", events[0].GetResourceChangeEvent().GetResourceId().GetResource())
+}
+
+func Test_AppUsageFilter(t *testing.T) {
+	app := &oktaSDK.LogTarget{Type: "AppInstance", Id: "0oa1"}
+
+	event := testLogEvent(eventTypeUserAuthenticationSSO, nil, app)
+	require.True(t, AppUsageFilter.Matches(event))
+	require.False(t, AppUsageFilter.Matches(testLogEvent(eventTypeUserSessionStart, nil)))
+
+	events, err := AppUsageFilter.Handle(event)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "evt1:app:0oa1", events[0].Id)
+
+	usage := events[0].GetUsageEvent()
+	require.NotNil(t, usage)
+	require.Equal(t, &v2.ResourceId{ResourceType: resourceTypeApp.Id, Resource: "0oa1"}, usage.TargetResource.Id)
+	require.Equal(t, &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "00uadmin"}, usage.ActorResource.Id)
+
+	events, err = AppUsageFilter.Handle(testLogEvent(eventTypeUserSessionStart, nil, app, &oktaSDK.LogTarget{Type: "AppInstance", Id: "0oa2"}))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "evt1:app:0oa2", events[1].Id)
+
+	sessionStart := testLogEvent(eventTypeUserSessionStart, nil, app)
+	changes, err := UserAuthenticationFilter.Handle(sessionStart)
+	require.NoError(t, err)
+	events, err = AppUsageFilter.Handle(sessionStart)
+	require.NoError(t, err)
+	require.NotEqual(t, changes[0].Id, events[0].Id)
+
+	event.Outcome.Result = "FAILURE"
+	events, err = AppUsageFilter.Handle(event)
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func Test_SecuritySignalFilter(t *testing.T) {
+	event := testLogEvent("user.account.lock", nil, &oktaSDK.LogTarget{Type: "User", Id: "00u1"})
+	require.True(t, SecuritySignalFilter.Matches(event))
+	require.False(t, UserStatusFilter.Matches(event))
+
+	events, err := SecuritySignalFilter.Handle(event)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "evt1", events[0].Id)
+	require.Equal(t, "00u1", events[0].GetResourceChangeEvent().GetResourceId().GetResource())
+
+	events, err = SecuritySignalFilter.Handle(testLogEvent("security.threat.detected", nil))
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	events, err = SecuritySignalFilter.Handle(testLogEvent("user.risk.detect", nil,
+		&oktaSDK.LogTarget{Type: "User", Id: "00u1"}, &oktaSDK.LogTarget{Type: "User", Id: "00u2"}))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "evt1:00u2", events[1].Id)
+}
+
+func Test_newEventFilters(t *testing.T) {
+	filters, extra, err := newEventFilters(nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, extra)
+	require.Len(t, filters, 4)
+	require.NotContains(t, filters, EventFilterGroupAuthentication)
+
+	filters, extra, err = newEventFilters([]string{"Authentication", "authentication"}, []string{"user.session.start", " user.mfa.factor.unenroll ", ""})
+	require.NoError(t, err)
+	require.Len(t, filters, 1)
+	require.NotNil(t, extra)
+	require.ElementsMatch(t, []string{"user.mfa.factor.unenroll"}, extra.EventTypes.ToSlice())
+
+	events, err := extra.Handle(testLogEvent("user.mfa.factor.unenroll", nil, &oktaSDK.LogTarget{Type: "User", Id: "00u1"}))
+	require.NoError(t, err)
+	require.Equal(t, "00u1", events[0].GetResourceChangeEvent().GetResourceId().GetResource())
+
+	_, _, err = newEventFilters([]string{"logins"}, nil)
+	require.Error(t, err)
+}
@@ -15,7 +15,7 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-func (connector *Okta) createQueryParams(earliestEvent *timestamppb.Timestamp, pToken *pagination.StreamToken, filters ...string) *query.Params {
+func createQueryParams(earliestEvent *timestamppb.Timestamp, pToken *pagination.StreamToken, filters ...string) *query.Params {
 	qp := queryParams(pToken.Size, pToken.Cursor)
 	if earliestEvent != nil {
 		qp.Since = earliestEvent.AsTime().Format(time.RFC3339)
@@ -33,15 +33,17 @@ func (connector *Okta) createQueryParams(earliestEvent *timestamppb.Timestamp, p
 	return qp
 }
 
-func (connector *Okta) ListEvents(
+// ListEvents lists the System Log events matching the filters of the feed.
+func (feed *oktaEventFeed) ListEvents(
 	ctx context.Context,
 	earliestEvent *timestamppb.Timestamp,
 	pToken *pagination.StreamToken,
 ) ([]*v2.Event, *pagination.StreamState, annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
-	// MJP this will eventually come from config/request?
-	activeFilters := []EventFilter{
-		UserLifecycleFilter,
+	activeFilters := feed.filters
+	// Without filters every event would be requested, none of which would be handled.
+	if len(activeFilters) == 0 {
+		return nil, &pagination.StreamState{Cursor: pToken.Cursor, HasMore: false}, nil, nil
 	}
 
 	// Map from event type to possible filter matches
@@ -57,9 +59,9 @@ func (connector *Okta) ListEvents(
 		filters = append(filters, filter.Filter())
 	}
 
-	qp := connector.createQueryParams(earliestEvent, pToken, filters...)
+	qp := createQueryParams(earliestEvent, pToken, filters...)
 
-	logs, resp, err := connector.client.LogEvent.GetLogs(ctx, qp)
+	logs, resp, err := feed.connector.client.LogEvent.GetLogs(ctx, qp)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -70,12 +72,12 @@ func (connector *Okta) ListEvents(
 		relevantFilters := filterMap[log.EventType]
 		for _, filter := range relevantFilters {
 			if filter.Matches(log) {
-				event, err := filter.Handle(log)
+				events, err := filter.Handle(log)
 				// MJP we don't want to stop, we should just log the error and continue
 				if err != nil {
 					l.Error("error handling event", zap.Error(err), zap.String("event_type", log.EventType))
 				} else {
-					rv = append(rv, event)
+					rv = append(rv, events...)
 				}
 			}
 		}
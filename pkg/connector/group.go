@@ -0,0 +1,462 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	sdkEntitlement "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	sdkGrant "github.com/conductorone/baton-sdk/pkg/types/grant"
+	sdkResource "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/okta/okta-sdk-golang/v2/okta/query"
+	"go.uber.org/zap"
+)
+
+const (
+	groupTypeOkta    = "OKTA_GROUP"
+	groupTypeApp     = "APP_GROUP"
+	groupTypeBuiltIn = "BUILT_IN"
+
+	groupMemberEntitlement = "member"
+)
+
+// Group types are listed separately so that each type gets its own page state in the bag.
+var groupTypes = []string{
+	groupTypeOkta,
+	groupTypeApp,
+	groupTypeBuiltIn,
+}
+
+type groupResourceType struct {
+	resourceType *v2.ResourceType
+	emailFilters []string
+	connector    *Okta
+}
+
+func (o *groupResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return o.resourceType
+}
+
+func (o *groupResourceType) List(
+	ctx context.Context,
+	resourceID *v2.ResourceId,
+	token *pagination.Token,
+) ([]*v2.Resource, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	bag := &pagination.Bag{}
+	err := bag.Unmarshal(token.Token)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse page token: %w", err)
+	}
+
+	if bag.Current() == nil {
+		for i := len(groupTypes) - 1; i >= 0; i-- {
+			bag.Push(pagination.PageState{
+				ResourceTypeID: resourceTypeGroup.Id,
+				ResourceID:     groupTypes[i],
+			})
+		}
+	}
+
+	current := bag.Current()
+	l.Debug("Listing groups", zap.String("group_type", current.ResourceID), zap.Any("bag", bag))
+
+	qp := queryParams(token.Size, current.Token)
+	qp.Filter = filterMaker("type", current.ResourceID)
+
+	groups, respCtx, err := listGroups(ctx, o.connector.client, token, qp)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list groups: %w", err)
+	}
+
+	nextPage, annos, err := parseResp(respCtx.OktaResponse)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	err = bag.Next(nextPage)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to fetch bag.Next: %w", err)
+	}
+
+	var rv []*v2.Resource
+	for _, group := range groups {
+		resource, err := groupResource(ctx, group)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, resource)
+	}
+
+	pageToken, err := bag.Marshal()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, pageToken, annos, nil
+}
+
+func (o *groupResourceType) Entitlements(
+	_ context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	en := sdkEntitlement.NewAssignmentEntitlement(resource, groupMemberEntitlement,
+		sdkEntitlement.WithDisplayName(fmt.Sprintf("%s Group Member", resource.DisplayName)),
+		sdkEntitlement.WithDescription(fmt.Sprintf("Member of %s group in Okta", resource.DisplayName)),
+		sdkEntitlement.WithAnnotation(&v2.V1Identifier{
+			Id: V1MembershipEntitlementID(resource.Id.GetResource()),
+		}),
+		sdkEntitlement.WithGrantableTo(resourceTypeUser),
+	)
+
+	rv := []*v2.Entitlement{en}
+	rv = append(rv, roleTargetEntitlements(resource, groupTargetRoleTypes)...)
+
+	return rv, "", nil, nil
+}
+
+// Group members are listed with the page state resource set to whether members outside of the CIAM email domains
+// are included, which is decided once on the first page.
+const (
+	groupMembersInDomain = "in-domain"
+	groupMembersAll      = "all"
+)
+
+func (o *groupResourceType) Grants(
+	ctx context.Context,
+	resource *v2.Resource,
+	token *pagination.Token,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	bag := &pagination.Bag{}
+	err := bag.Unmarshal(token.Token)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse page token: %w", err)
+	}
+
+	var rv []*v2.Grant
+	if bag.Current() == nil {
+		// Members of groups holding admin roles are synced regardless of their email domain, so their
+		// memberships are needed to expand the group's role grants.
+		roles, err := listGroupAssignedRoles(ctx, o.connector.client, resource.Id.GetResource())
+		if err != nil && !errors.Is(err, errMissingRolePermissions) {
+			return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list group roles: %w", err)
+		}
+
+		grants, err := o.groupRoleGrants(ctx, resource, roles)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		rv = append(rv, grants...)
+
+		members := groupMembersInDomain
+		if len(roles) > 0 {
+			members = groupMembersAll
+		}
+		bag.Push(pagination.PageState{
+			ResourceTypeID: resourceTypeUser.Id,
+			ResourceID:     members,
+		})
+	}
+
+	current := bag.Current()
+	qp := queryParams(token.Size, current.Token)
+	users, respCtx, err := listGroupUsers(ctx, o.connector.client, resource.Id.GetResource(), token, qp)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list group users: %w", err)
+	}
+
+	nextPage, annos, err := parseResp(respCtx.OktaResponse)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	err = bag.Next(nextPage)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to fetch bag.Next: %w", err)
+	}
+
+	for _, user := range users {
+		// Users outside of the CIAM email domains are never synced, so don't emit grants for them.
+		if current.ResourceID != groupMembersAll && !shouldIncludeOktaUser(user, o.emailFilters) {
+			continue
+		}
+		rv = append(rv, groupGrant(resource, user.Id))
+	}
+
+	pageToken, err := bag.Marshal()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, pageToken, annos, nil
+}
+
+// groupRoleGrants returns the grants of the standard and custom roles assigned to a group, and of the role target
+// entitlements of the groups and apps the standard roles are scoped to. Role grants are made here rather than by the
+// role syncer, so the roles of each group are only read once per sync.
+func (o *groupResourceType) groupRoleGrants(ctx context.Context, resource *v2.Resource, roles []*Roles) ([]*v2.Grant, error) {
+	groupID := resource.Id.GetResource()
+	principalID := &v2.ResourceId{ResourceType: resourceTypeGroup.Id, Resource: groupID}
+
+	var rv []*v2.Grant
+	for _, role := range roles {
+		if role.AssignmentType != roleAssignmentTypeGroup || role.Status != roleStatusActive {
+			continue
+		}
+
+		switch {
+		case standardRoleFromType(role.Type) != nil:
+			targets, err := listRoleTargets(ctx, o.connector.client, principalID, role)
+			if err != nil {
+				return nil, fmt.Errorf("okta-connectorv2: failed to list group role targets: %w", err)
+			}
+
+			rr := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeRole.Id, Resource: role.Type}}
+			rv = append(rv, roleGroupGrant(groupID, rr, roleAssignmentGrantMetadata(role, targets)))
+			rv = append(rv, roleTargetGrants(principalID, role, targets)...)
+		case role.Type == roleTypeCustom && o.connector.syncCustomRoles:
+			rr := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeCustomRole.Id, Resource: role.Role}}
+			rv = append(rv, roleGroupGrant(groupID, rr, roleAssignmentGrantMetadata(role, nil)))
+		}
+	}
+
+	return rv, nil
+}
+
+func (o *groupResourceType) Get(ctx context.Context, resourceId *v2.ResourceId, parentResourceId *v2.ResourceId) (*v2.Resource, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	l.Debug("getting group", zap.String("group_id", resourceId.Resource))
+
+	group, resp, err := o.connector.client.Group.GetGroup(ctx, resourceId.Resource)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connectorv2: failed to find group: %w", handleOktaResponseError(resp, err))
+	}
+
+	_, annos, err := parseResp(resp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	resource, err := groupResource(ctx, group)
+	if err != nil {
+		return nil, annos, err
+	}
+
+	return resource, annos, nil
+}
+
+func (o *groupResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	if roleType := roleTypeFromTargetEntitlementSlug(entitlement.Slug); slices.Contains(groupTargetRoleTypes, roleType) {
+		return grantRoleTarget(ctx, o.connector.client, principal.Id, roleType, &roleTarget{GroupID: entitlement.Resource.Id.Resource})
+	}
+
+	if principal.Id.ResourceType != resourceTypeUser.Id {
+		l.Warn(
+			"okta-connector: only users can be granted group membership",
+			zap.String("principal_type", principal.Id.ResourceType),
+			zap.String("principal_id", principal.Id.Resource),
+		)
+		return nil, fmt.Errorf("okta-connector: only users can be granted group membership")
+	}
+
+	groupId := entitlement.Resource.Id.Resource
+	userId := principal.Id.Resource
+	err := o.ensureGroupIsWritable(ctx, groupId)
+	if err != nil {
+		return nil, err
+	}
+
+	isMember, err := userIsGroupMember(ctx, o.connector.client, groupId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if isMember {
+		l.Warn(
+			"okta-connector: The user specified is already a member of the group",
+			zap.String("principal_id", principal.Id.String()),
+			zap.String("principal_type", principal.Id.ResourceType),
+			zap.String("group_id", groupId),
+		)
+		return annotations.New(&v2.GrantAlreadyExists{}), nil
+	}
+
+	response, err := o.connector.client.Group.AddUserToGroup(ctx, groupId, userId)
+	if err != nil {
+		return nil, fmt.Errorf("okta-connector: failed to add user to group: %w", handleOktaResponseError(response, err))
+	}
+
+	l.Warn("Group Membership has been created.",
+		zap.String("Status", response.Status),
+		zap.String("group_id", groupId),
+		zap.String("user_id", userId),
+	)
+
+	return nil, nil
+}
+
+func (o *groupResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	entitlement := grant.Entitlement
+	principal := grant.Principal
+	if roleType := roleTypeFromTargetEntitlementSlug(entitlement.Slug); slices.Contains(groupTargetRoleTypes, roleType) {
+		return revokeRoleTarget(ctx, o.connector.client, principal.Id, roleType, &roleTarget{GroupID: entitlement.Resource.Id.Resource})
+	}
+
+	if principal.Id.ResourceType != resourceTypeUser.Id {
+		l.Warn(
+			"okta-connector: only users can have group membership revoked",
+			zap.String("principal_type", principal.Id.ResourceType),
+			zap.String("principal_id", principal.Id.Resource),
+		)
+		return nil, fmt.Errorf("okta-connector: only users can have group membership revoked")
+	}
+
+	groupId := entitlement.Resource.Id.Resource
+	userId := principal.Id.Resource
+	err := o.ensureGroupIsWritable(ctx, groupId)
+	if err != nil {
+		return nil, err
+	}
+
+	isMember, err := userIsGroupMember(ctx, o.connector.client, groupId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		l.Warn(
+			"okta-connector: user is not a member of the group",
+			zap.String("principal_id", principal.Id.String()),
+			zap.String("principal_type", principal.Id.ResourceType),
+			zap.String("group_id", groupId),
+		)
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
+
+	response, err := o.connector.client.Group.RemoveUserFromGroup(ctx, groupId, userId)
+	if err != nil {
+		return nil, fmt.Errorf("okta-connector: failed to remove user from group: %w", handleOktaResponseError(response, err))
+	}
+
+	l.Warn("Group Membership has been revoked",
+		zap.String("Status", response.Status),
+		zap.String("group_id", groupId),
+		zap.String("user_id", userId),
+	)
+
+	return nil, nil
+}
+
+// Only OKTA_GROUP memberships can be changed. APP_GROUP memberships are managed by the source app
+// and BUILT_IN groups (e.g. Everyone) are managed by Okta.
+func (o *groupResourceType) ensureGroupIsWritable(ctx context.Context, groupId string) error {
+	group, resp, err := o.connector.client.Group.GetGroup(ctx, groupId)
+	if err != nil {
+		return fmt.Errorf("okta-connector: failed to get group: %w", handleOktaResponseError(resp, err))
+	}
+
+	if group.Type != groupTypeOkta {
+		return fmt.Errorf("okta-connector: group %s is of type %s and its membership is read-only, only %s groups can be modified", groupId, group.Type, groupTypeOkta)
+	}
+
+	return nil
+}
+
+// userIsGroupMember reads the groups of the user past the response cache, since membership changes don't clear
+// the cached groups of the user.
+func userIsGroupMember(ctx context.Context, client *okta.Client, groupId string, userId string) (bool, error) {
+	reqUrl, err := url.JoinPath(usersUrl, userId, "groups")
+	if err != nil {
+		return false, err
+	}
+
+	groups, resp, err := listUncached[*okta.Group](ctx, client, reqUrl)
+	if err != nil {
+		return false, fmt.Errorf("okta-connector: failed to list user groups: %w", handleOktaResponseError(resp, err))
+	}
+
+	return slices.ContainsFunc(groups, func(g *okta.Group) bool {
+		return g.Id == groupId
+	}), nil
+}
+
+func listGroups(ctx context.Context, client *okta.Client, token *pagination.Token, qp *query.Params) ([]*okta.Group, *responseContext, error) {
+	groups, resp, err := client.Group.ListGroups(ctx, qp)
+	if err != nil {
+		return nil, nil, handleOktaResponseError(resp, err)
+	}
+
+	respCtx, err := responseToContext(token, resp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return groups, respCtx, nil
+}
+
+func listGroupUsers(ctx context.Context, client *okta.Client, groupID string, token *pagination.Token, qp *query.Params) ([]*okta.User, *responseContext, error) {
+	users, resp, err := client.Group.ListGroupUsers(ctx, groupID, qp)
+	if err != nil {
+		return nil, nil, handleOktaResponseError(resp, err)
+	}
+
+	respCtx, err := responseToContext(token, resp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return users, respCtx, nil
+}
+
+// Create a new connector resource for an okta group.
+func groupResource(ctx context.Context, group *okta.Group) (*v2.Resource, error) {
+	var name, description string
+	if group.Profile != nil {
+		name = group.Profile.Name
+		description = group.Profile.Description
+	}
+
+	profile := map[string]interface{}{
+		"id":          group.Id,
+		"name":        name,
+		"description": description,
+		"type":        group.Type,
+	}
+
+	return sdkResource.NewGroupResource(
+		name,
+		resourceTypeGroup,
+		group.Id,
+		[]sdkResource.GroupTraitOption{sdkResource.WithGroupProfile(profile)},
+		sdkResource.WithAnnotation(&v2.V1Identifier{
+			Id: fmtResourceIdV1(group.Id),
+		}),
+	)
+}
+
+func groupGrant(resource *v2.Resource, userID string) *v2.Grant {
+	ur := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: userID}}
+
+	return sdkGrant.NewGrant(resource, groupMemberEntitlement, ur,
+		sdkGrant.WithAnnotation(&v2.V1Identifier{
+			Id: fmtGrantIdV1(V1MembershipEntitlementID(resource.Id.Resource), userID),
+		}),
+	)
+}
+
+func groupBuilder(connector *Okta) *groupResourceType {
+	return &groupResourceType{
+		resourceType: resourceTypeGroup,
+		emailFilters: lowerEmailDomains(connector.ciamConfig.EmailDomains),
+		connector:    connector,
+	}
+}
@@ -0,0 +1,113 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_userIsGroupMember(t *testing.T) {
+	member := true
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/users/00u1/groups", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("after") == "" {
+			w.Header().Set("Link", `<http://okta.test/api/v1/users/00u1/groups?after=00g1>; rel="next"`)
+			_, _ = w.Write([]byte(`[{"id": "00g1"}]`))
+			return
+		}
+		if member {
+			_, _ = w.Write([]byte(`[{"id": "00g2"}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	})
+	client := newTestClient(t, mux)
+	ctx := context.Background()
+
+	for _, want := range []bool{true, false} {
+		member = want
+		t.Run(fmt.Sprintf("member %t", want), func(t *testing.T) {
+			isMember, err := userIsGroupMember(ctx, client, "00g2", "00u1")
+			require.NoError(t, err)
+			require.Equal(t, want, isMember)
+		})
+	}
+}
+
+func Test_groupGrants(t *testing.T) {
+	roleReads := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/groups/00g1/roles", func(w http.ResponseWriter, r *http.Request) {
+		roleReads++
+		_, _ = w.Write([]byte(`[
+			{"id": "ra1", "type": "HELP_DESK_ADMIN", "status": "ACTIVE", "assignmentType": "GROUP"},
+			{"id": "ra2", "type": "CUSTOM", "role": "cr1", "status": "ACTIVE", "assignmentType": "GROUP"}
+		]`))
+	})
+	mux.HandleFunc("GET /api/v1/groups/00g1/roles/ra1/targets/groups", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"id": "00g2"}]`))
+	})
+	mux.HandleFunc("GET /api/v1/groups/00g1/users", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("after") == "" {
+			w.Header().Set("Link", `<http://okta.test/api/v1/groups/00g1/users?after=00u1>; rel="next"`)
+			_, _ = w.Write([]byte(`[{"id": "00u1", "profile": {"email": "admin@corp.example"}}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id": "00u2", "profile": {"email": "jane@example.com"}}]`))
+	})
+	o := &groupResourceType{
+		resourceType: resourceTypeGroup,
+		emailFilters: []string{"example.com"},
+		connector:    &Okta{client: newTestClient(t, mux, okta.WithCache(false))},
+	}
+	ctx := context.Background()
+	resource := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeGroup.Id, Resource: "00g1"}}
+
+	var entitlements []string
+	var principals []string
+	token := &pagination.Token{}
+	for {
+		grants, next, _, err := o.Grants(ctx, resource, token)
+		require.NoError(t, err)
+		for _, grant := range grants {
+			entitlements = append(entitlements, grant.Entitlement.Id)
+			principals = append(principals, grant.Principal.Id.Resource)
+		}
+		if next == "" {
+			break
+		}
+		token = &pagination.Token{Token: next}
+	}
+
+	require.Equal(t, 1, roleReads)
+	require.Equal(t, []string{
+		"role:HELP_DESK_ADMIN:assigned", "group:00g2:help_desk_admin", "group:00g1:member", "group:00g1:member",
+	}, entitlements)
+	require.Equal(t, []string{"00g1", "00g1", "00u1", "00u2"}, principals)
+}
+
+func Test_groupRoleGrantsCustomRoles(t *testing.T) {
+	o := &groupResourceType{connector: &Okta{syncCustomRoles: true}}
+	resource := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeGroup.Id, Resource: "00g1"}}
+	roles := []*Roles{
+		{Id: "ra2", Type: roleTypeCustom, Role: "cr1", Status: userStatusActive, AssignmentType: roleAssignmentTypeGroup},
+		{Id: "ra3", Type: roleTypeCustom, Role: "cr2", Status: "INACTIVE", AssignmentType: roleAssignmentTypeGroup},
+	}
+
+	grants, err := o.groupRoleGrants(context.Background(), resource, roles)
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	require.Equal(t, "custom-role:cr1:assigned", grants[0].Entitlement.Id)
+	require.Equal(t, "00g1", grants[0].Principal.Id.Resource)
+
+	o.connector.syncCustomRoles = false
+	grants, err = o.groupRoleGrants(context.Background(), resource, roles)
+	require.NoError(t, err)
+	require.Empty(t, grants)
+}
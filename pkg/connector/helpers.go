@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 
 	"github.com/conductorone/baton-sdk/pkg/pagination"
@@ -61,6 +62,66 @@ func responseToContext(token *pagination.Token, resp *okta.Response) (*responseC
 	}, nil
 }
 
+// nextPageFromLink returns the after cursor of the next link that the IAM APIs include in the response body.
+func nextPageFromLink(link Link) (string, error) {
+	if link.Next.Href == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(link.Next.Href)
+	if err != nil {
+		return "", err
+	}
+
+	return u.Query().Get("after"), nil
+}
+
+func doRequest(ctx context.Context, client *okta.Client, method string, reqUrl string, body interface{}, v interface{}) (*okta.Response, error) {
+	rq := client.CloneRequestExecutor()
+	req, err := rq.
+		WithAccept(ContentType).
+		WithContentType(ContentType).
+		NewRequest(method, reqUrl, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return rq.Do(ctx, req, v)
+}
+
+// doUncachedRequest is doRequest bypassing the response cache, for reads that have to see changes made moments ago.
+func doUncachedRequest(ctx context.Context, client *okta.Client, method string, reqUrl string, body interface{}, v interface{}) (*okta.Response, error) {
+	rq := client.CloneRequestExecutor()
+	req, err := rq.
+		WithAccept(ContentType).
+		WithContentType(ContentType).
+		NewRequest(method, reqUrl, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return rq.RefreshNext().Do(ctx, req, v)
+}
+
+// listUncached reads every page of a list past the response cache.
+func listUncached[T any](ctx context.Context, client *okta.Client, reqUrl string) ([]T, *okta.Response, error) {
+	var rv []T
+	var resp *okta.Response
+	for reqUrl != "" {
+		var page []T
+		var err error
+		resp, err = doUncachedRequest(ctx, client, http.MethodGet, reqUrl, nil, &page)
+		if err != nil {
+			return nil, resp, err
+		}
+
+		rv = append(rv, page...)
+		reqUrl = resp.NextPage
+	}
+
+	return rv, resp, nil
+}
+
 func getError(response *okta.Response) (okta.Error, error) {
 	var errOkta okta.Error
 	bytes, err := io.ReadAll(response.Body)
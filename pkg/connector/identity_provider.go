@@ -0,0 +1,223 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	sdkEntitlement "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	sdkGrant "github.com/conductorone/baton-sdk/pkg/types/grant"
+	sdkResource "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/okta/okta-sdk-golang/v2/okta/query"
+	"go.uber.org/zap"
+)
+
+const (
+	apiPathIdentityProviders = "/api/v1/idps"
+
+	identityProviderLinkedEntitlement = "linked"
+)
+
+type identityProviderResourceType struct {
+	resourceType *v2.ResourceType
+	emailFilters []string
+	connector    *Okta
+}
+
+func (o *identityProviderResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return o.resourceType
+}
+
+func (o *identityProviderResourceType) List(
+	ctx context.Context,
+	resourceID *v2.ResourceId,
+	token *pagination.Token,
+) ([]*v2.Resource, string, annotations.Annotations, error) {
+	bag, page, err := parsePageToken(token.Token, &v2.ResourceId{ResourceType: resourceTypeIdentityProvider.Id})
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse page token: %w", err)
+	}
+
+	qp := queryParams(token.Size, page)
+	idps, resp, err := o.connector.client.IdentityProvider.ListIdentityProviders(ctx, qp)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list identity providers: %w", handleOktaResponseError(resp, err))
+	}
+
+	nextPage, annos, err := parseResp(resp)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	err = bag.Next(nextPage)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to fetch bag.Next: %w", err)
+	}
+
+	var rv []*v2.Resource
+	for _, idp := range idps {
+		resource, err := identityProviderResource(ctx, idp)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, resource)
+	}
+
+	pageToken, err := bag.Marshal()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, pageToken, annos, nil
+}
+
+func (o *identityProviderResourceType) Entitlements(
+	_ context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	en := sdkEntitlement.NewAssignmentEntitlement(resource, identityProviderLinkedEntitlement,
+		sdkEntitlement.WithDisplayName(fmt.Sprintf("%s Linked User", resource.DisplayName)),
+		sdkEntitlement.WithDescription(fmt.Sprintf("Linked to the %s identity provider in Okta", resource.DisplayName)),
+	)
+
+	return []*v2.Entitlement{en}, "", nil, nil
+}
+
+func (o *identityProviderResourceType) Grants(
+	ctx context.Context,
+	resource *v2.Resource,
+	token *pagination.Token,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	bag, page, err := parsePageToken(token.Token, resource.Id)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse page token: %w", err)
+	}
+
+	qp := queryParams(token.Size, page)
+	idpUsers, resp, err := listIdentityProviderUsers(ctx, o.connector.client, resource.Id.GetResource(), qp)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list identity provider users: %w", err)
+	}
+
+	nextPage, annos, err := parseResp(resp)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	err = bag.Next(nextPage)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to fetch bag.Next: %w", err)
+	}
+
+	var rv []*v2.Grant
+	for _, idpUser := range idpUsers {
+		user, err := embeddedUser(idpUser.Embedded)
+		if err != nil {
+			l.Warn("okta-connectorv2: failed to read embedded identity provider user", zap.String("user_id", idpUser.Id), zap.Error(err))
+			continue
+		}
+		if user == nil {
+			l.Warn("okta-connectorv2: identity provider user is missing the embedded user", zap.String("user_id", idpUser.Id))
+			continue
+		}
+
+		// Users outside of the CIAM email domains are never synced, so don't emit grants for them.
+		if !shouldIncludeOktaUser(user, o.emailFilters) {
+			continue
+		}
+
+		rv = append(rv, identityProviderGrant(resource, idpUser))
+	}
+
+	pageToken, err := bag.Marshal()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, pageToken, annos, nil
+}
+
+func (o *identityProviderResourceType) Get(ctx context.Context, resourceId *v2.ResourceId, parentResourceId *v2.ResourceId) (*v2.Resource, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	l.Debug("getting identity provider", zap.String("idp_id", resourceId.Resource))
+
+	idp, resp, err := o.connector.client.IdentityProvider.GetIdentityProvider(ctx, resourceId.Resource)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connectorv2: failed to find identity provider: %w", handleOktaResponseError(resp, err))
+	}
+
+	_, annos, err := parseResp(resp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	resource, err := identityProviderResource(ctx, idp)
+	if err != nil {
+		return nil, annos, err
+	}
+
+	return resource, annos, nil
+}
+
+// listIdentityProviderUsers lists the users linked to an identity provider. The SDK doesn't take query
+// parameters for this endpoint, which are needed for pagination and for embedding the okta users.
+func listIdentityProviderUsers(ctx context.Context, client *okta.Client, idpID string, qp *query.Params) ([]*okta.IdentityProviderApplicationUser, *okta.Response, error) {
+	reqUrl, err := url.JoinPath(apiPathIdentityProviders, idpID, "users")
+	if err != nil {
+		return nil, nil, err
+	}
+	qp.Expand = "user"
+
+	var idpUsers []*okta.IdentityProviderApplicationUser
+	resp, err := doRequest(ctx, client, http.MethodGet, reqUrl+qp.String(), nil, &idpUsers)
+	if err != nil {
+		return nil, nil, handleOktaResponseError(resp, err)
+	}
+
+	return idpUsers, resp, nil
+}
+
+// Create a new connector resource for an okta identity provider.
+func identityProviderResource(ctx context.Context, idp *okta.IdentityProvider) (*v2.Resource, error) {
+	description := fmt.Sprintf("%s identity provider", idp.Type)
+	if idp.Protocol != nil && idp.Protocol.Type != "" {
+		description = fmt.Sprintf("%s identity provider using %s", idp.Type, idp.Protocol.Type)
+	}
+
+	return sdkResource.NewResource(
+		idp.Name,
+		resourceTypeIdentityProvider,
+		idp.Id,
+		sdkResource.WithDescription(description),
+		sdkResource.WithAnnotation(&v2.V1Identifier{
+			Id: fmtResourceIdV1(idp.Id),
+		}),
+	)
+}
+
+func identityProviderGrant(resource *v2.Resource, idpUser *okta.IdentityProviderApplicationUser) *v2.Grant {
+	ur := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: idpUser.Id}}
+
+	return sdkGrant.NewGrant(resource, identityProviderLinkedEntitlement, ur,
+		sdkGrant.WithGrantMetadata(map[string]interface{}{
+			"external_id": idpUser.ExternalId,
+		}),
+	)
+}
+
+func identityProviderBuilder(connector *Okta) *identityProviderResourceType {
+	return &identityProviderResourceType{
+		resourceType: resourceTypeIdentityProvider,
+		emailFilters: lowerEmailDomains(connector.ciamConfig.EmailDomains),
+		connector:    connector,
+	}
+}
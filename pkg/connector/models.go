@@ -70,6 +70,7 @@ type ResourceSet struct {
 type _Links struct {
 	ResourceSet ResourceSet `json:"resource-set,omitempty"`
 	Self        Self        `json:"self,omitempty"`
+	Next        Next        `json:"next,omitempty"`
 }
 
 type MembersDetails struct {
@@ -79,6 +80,15 @@ type MembersDetails struct {
 	Links       LinksSelf `json:"_links,omitempty"`
 }
 
+type BindingMembersAPIData struct {
+	Members []MembersDetails `json:"members,omitempty"`
+	Links   Link             `json:"_links,omitempty"`
+}
+
+type BindingMembersUpdate struct {
+	Additions []string `json:"additions,omitempty"`
+}
+
 type LinksSelfBinding struct {
 	Self    Self    `json:"self,omitempty"`
 	Binding Binding `json:"binding,omitempty"`
@@ -91,3 +101,62 @@ type LinksSelf struct {
 type Binding struct {
 	Href string `json:"href,omitempty"`
 }
+
+type CustomRolesAPIData struct {
+	Roles []*CustomRole `json:"roles,omitempty"`
+	Links Link          `json:"_links,omitempty"`
+}
+
+type CustomRole struct {
+	ID          string      `json:"id,omitempty"`
+	Label       string      `json:"label,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Created     *time.Time  `json:"created,omitempty"`
+	LastUpdated *time.Time  `json:"lastUpdated,omitempty"`
+	Links       interface{} `json:"_links,omitempty"`
+}
+
+type PermissionsAPIData struct {
+	Permissions []*Permission `json:"permissions,omitempty"`
+	Links       interface{}   `json:"_links,omitempty"`
+}
+
+type Permission struct {
+	Label       string      `json:"label,omitempty"`
+	Created     *time.Time  `json:"created,omitempty"`
+	LastUpdated *time.Time  `json:"lastUpdated,omitempty"`
+	Links       interface{} `json:"_links,omitempty"`
+}
+
+type RoleAssigneesAPIData struct {
+	Value []*RoleAssignee `json:"value,omitempty"`
+	Links Link            `json:"_links,omitempty"`
+}
+
+// RoleAssignee is a principal with at least one role assignment.
+type RoleAssignee struct {
+	ID    string      `json:"id,omitempty"`
+	Orn   string      `json:"orn,omitempty"`
+	Links interface{} `json:"_links,omitempty"`
+}
+
+type PasswordPolicy struct {
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Status   string `json:"status,omitempty"`
+	System   bool   `json:"system,omitempty"`
+	Settings struct {
+		Password struct {
+			Complexity PasswordComplexity `json:"complexity"`
+		} `json:"password"`
+	} `json:"settings"`
+}
+
+// PasswordComplexity holds the minimum counts a password policy requires, the character class minimums are 0 or 1.
+type PasswordComplexity struct {
+	MinLength    int64 `json:"minLength,omitempty"`
+	MinLowerCase int64 `json:"minLowerCase,omitempty"`
+	MinUpperCase int64 `json:"minUpperCase,omitempty"`
+	MinNumber    int64 `json:"minNumber,omitempty"`
+	MinSymbol    int64 `json:"minSymbol,omitempty"`
+}
@@ -0,0 +1,586 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	sdkEntitlement "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	sdkGrant "github.com/conductorone/baton-sdk/pkg/types/grant"
+	sdkResource "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"go.uber.org/zap"
+)
+
+const (
+	apiPathListIamResourceSets = "/api/v1/iam/resource-sets"
+
+	resourceSetBindingsEntitlement = "bindings"
+	bindingMemberEntitlement       = "member"
+)
+
+type resourceSetResourceType struct {
+	resourceType *v2.ResourceType
+	connector    *Okta
+}
+
+func (o *resourceSetResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return o.resourceType
+}
+
+func (o *resourceSetResourceType) List(
+	ctx context.Context,
+	resourceID *v2.ResourceId,
+	token *pagination.Token,
+) ([]*v2.Resource, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	bag, page, err := parsePageToken(token.Token, &v2.ResourceId{ResourceType: resourceTypeResourceSet.Id})
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse page token: %w", err)
+	}
+
+	resourceSets, nextPage, respCtx, err := listResourceSets(ctx, o.connector.client, page)
+	if err != nil {
+		if errors.Is(err, errMissingRolePermissions) {
+			l.Warn("okta-connectorv2: missing role permissions")
+			return nil, "", nil, nil
+		}
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list resource sets: %w", err)
+	}
+
+	_, annos, err := parseResp(respCtx.OktaResponse)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	err = bag.Next(nextPage)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to fetch bag.Next: %w", err)
+	}
+
+	var rv []*v2.Resource
+	for _, resourceSet := range resourceSets {
+		resource, err := resourceSetResource(ctx, resourceSet)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, resource)
+	}
+
+	pageToken, err := bag.Marshal()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, pageToken, annos, nil
+}
+
+func (o *resourceSetResourceType) Entitlements(
+	_ context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	en := sdkEntitlement.NewAssignmentEntitlement(resource, resourceSetBindingsEntitlement,
+		sdkEntitlement.WithDisplayName(fmt.Sprintf("%s Resource Set Binding", resource.DisplayName)),
+		sdkEntitlement.WithDescription(fmt.Sprintf("Custom role bound to the %s resource set in Okta", resource.DisplayName)),
+	)
+
+	return []*v2.Entitlement{en}, "", nil, nil
+}
+
+func (o *resourceSetResourceType) Grants(
+	ctx context.Context,
+	resource *v2.Resource,
+	token *pagination.Token,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	bag, page, err := parsePageToken(token.Token, resource.Id)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse page token: %w", err)
+	}
+
+	bindings, nextPage, respCtx, err := listResourceSetBindings(ctx, o.connector.client, resource.Id.GetResource(), page)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list resource set bindings: %w", err)
+	}
+
+	_, annos, err := parseResp(respCtx.OktaResponse)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	err = bag.Next(nextPage)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to fetch bag.Next: %w", err)
+	}
+
+	var rv []*v2.Grant
+	for _, binding := range bindings {
+		cr := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeCustomRole.Id, Resource: binding.ID}}
+		rv = append(rv, sdkGrant.NewGrant(resource, resourceSetBindingsEntitlement, cr))
+	}
+
+	pageToken, err := bag.Marshal()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, pageToken, annos, nil
+}
+
+type resourceSetBindingResourceType struct {
+	resourceType *v2.ResourceType
+	connector    *Okta
+}
+
+func (o *resourceSetBindingResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return o.resourceType
+}
+
+func (o *resourceSetBindingResourceType) List(
+	ctx context.Context,
+	parentResourceID *v2.ResourceId,
+	token *pagination.Token,
+) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID == nil || parentResourceID.ResourceType != resourceTypeResourceSet.Id {
+		return nil, "", nil, nil
+	}
+
+	bag, page, err := parsePageToken(token.Token, &v2.ResourceId{ResourceType: resourceTypeResourceSetBinding.Id})
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse page token: %w", err)
+	}
+
+	resourceSetID := parentResourceID.Resource
+	bindings, nextPage, respCtx, err := listResourceSetBindings(ctx, o.connector.client, resourceSetID, page)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list resource set bindings: %w", err)
+	}
+
+	_, annos, err := parseResp(respCtx.OktaResponse)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	err = bag.Next(nextPage)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to fetch bag.Next: %w", err)
+	}
+
+	var rv []*v2.Resource
+	for _, binding := range bindings {
+		role, err := getIamCustomRole(ctx, o.connector.client, binding.ID)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to get custom role: %w", err)
+		}
+
+		resource, err := resourceSetBindingResource(ctx, parentResourceID, role)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, resource)
+	}
+
+	pageToken, err := bag.Marshal()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, pageToken, annos, nil
+}
+
+func (o *resourceSetBindingResourceType) Entitlements(
+	_ context.Context,
+	resource *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	en := sdkEntitlement.NewAssignmentEntitlement(resource, bindingMemberEntitlement,
+		sdkEntitlement.WithDisplayName(fmt.Sprintf("%s Binding Member", resource.DisplayName)),
+		sdkEntitlement.WithDescription(fmt.Sprintf("Member of the %s resource set binding in Okta", resource.DisplayName)),
+		sdkEntitlement.WithGrantableTo(resourceTypeUser, resourceTypeGroup),
+	)
+
+	return []*v2.Entitlement{en}, "", nil, nil
+}
+
+func (o *resourceSetBindingResourceType) Grants(
+	ctx context.Context,
+	resource *v2.Resource,
+	token *pagination.Token,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	bag, page, err := parsePageToken(token.Token, resource.Id)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse page token: %w", err)
+	}
+
+	resourceSetID, roleID, err := parseBindingID(resource.Id.GetResource())
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	members, nextPage, respCtx, err := listBindingMembers(ctx, o.connector.client, resourceSetID, roleID, page)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list resource set binding members: %w", err)
+	}
+
+	_, annos, err := parseResp(respCtx.OktaResponse)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	err = bag.Next(nextPage)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to fetch bag.Next: %w", err)
+	}
+
+	var rv []*v2.Grant
+	for _, member := range members {
+		principalID, err := bindingMemberPrincipal(member)
+		if err != nil {
+			l.Warn("okta-connectorv2: skipping resource set binding member", zap.String("member_id", member.ID), zap.Error(err))
+			continue
+		}
+
+		rv = append(rv, bindingMemberGrant(resource, principalID))
+	}
+
+	pageToken, err := bag.Marshal()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return rv, pageToken, annos, nil
+}
+
+func (o *resourceSetBindingResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	if principal.Id.ResourceType != resourceTypeUser.Id && principal.Id.ResourceType != resourceTypeGroup.Id {
+		l.Warn(
+			"okta-connector: only users or groups can be granted resource set binding membership",
+			zap.String("principal_type", principal.Id.ResourceType),
+			zap.String("principal_id", principal.Id.Resource),
+		)
+		return nil, fmt.Errorf("okta-connector: only users or groups can be granted resource set binding membership")
+	}
+
+	resourceSetID, roleID, err := parseBindingID(entitlement.Resource.Id.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := findBindingMember(ctx, o.connector.client, resourceSetID, roleID, principal.Id)
+	if err != nil {
+		return nil, err
+	}
+	if member != nil {
+		l.Warn(
+			"okta-connector: The principal specified is already a member of the resource set binding",
+			zap.String("principal_id", principal.Id.String()),
+			zap.String("principal_type", principal.Id.ResourceType),
+			zap.String("resource_set_id", resourceSetID),
+			zap.String("role_id", roleID),
+		)
+		return annotations.New(&v2.GrantAlreadyExists{}), nil
+	}
+
+	reqUrl, err := url.JoinPath(apiPathListIamResourceSets, resourceSetID, "bindings", roleID, "members")
+	if err != nil {
+		return nil, err
+	}
+
+	body := BindingMembersUpdate{
+		Additions: []string{o.principalHref(principal.Id)},
+	}
+	response, err := doRequest(ctx, o.connector.client, http.MethodPatch, reqUrl, body, nil)
+	if err != nil {
+		return nil, fmt.Errorf("okta-connector: failed to add resource set binding member: %w", handleOktaResponseError(response, err))
+	}
+
+	l.Warn("Resource set binding membership has been created.",
+		zap.String("resource_set_id", resourceSetID),
+		zap.String("role_id", roleID),
+		zap.String("principal_id", principal.Id.Resource),
+		zap.String("Status", response.Status),
+	)
+
+	return nil, nil
+}
+
+func (o *resourceSetBindingResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	principal := grant.Principal
+	if principal.Id.ResourceType != resourceTypeUser.Id && principal.Id.ResourceType != resourceTypeGroup.Id {
+		l.Warn(
+			"okta-connector: only users or groups can have resource set binding membership revoked",
+			zap.String("principal_type", principal.Id.ResourceType),
+			zap.String("principal_id", principal.Id.Resource),
+		)
+		return nil, fmt.Errorf("okta-connector: only users or groups can have resource set binding membership revoked")
+	}
+
+	resourceSetID, roleID, err := parseBindingID(grant.Entitlement.Resource.Id.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := findBindingMember(ctx, o.connector.client, resourceSetID, roleID, principal.Id)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		l.Warn(
+			"okta-connector: principal is not a member of the resource set binding",
+			zap.String("principal_id", principal.Id.String()),
+			zap.String("principal_type", principal.Id.ResourceType),
+			zap.String("resource_set_id", resourceSetID),
+			zap.String("role_id", roleID),
+		)
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
+
+	reqUrl, err := url.JoinPath(apiPathListIamResourceSets, resourceSetID, "bindings", roleID, "members", member.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := doRequest(ctx, o.connector.client, http.MethodDelete, reqUrl, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("okta-connector: failed to remove resource set binding member: %w", handleOktaResponseError(response, err))
+	}
+
+	l.Warn("Resource set binding membership has been revoked",
+		zap.String("Status", response.Status),
+	)
+
+	return nil, nil
+}
+
+func (o *resourceSetBindingResourceType) principalHref(principalID *v2.ResourceId) string {
+	collection := "users"
+	if principalID.ResourceType == resourceTypeGroup.Id {
+		collection = "groups"
+	}
+
+	return fmt.Sprintf("https://%s/api/v1/%s/%s", o.connector.domain, collection, principalID.Resource)
+}
+
+func listResourceSets(ctx context.Context, client *okta.Client, after string) ([]ResourceSets, string, *responseContext, error) {
+	reqUrl := apiPathListIamResourceSets
+	if after != "" {
+		reqUrl += "?" + url.Values{"after": []string{after}}.Encode()
+	}
+
+	var data ResourceSetsAPIData
+	resp, err := doRequest(ctx, client, http.MethodGet, reqUrl, nil, &data)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusForbidden {
+			return nil, "", nil, errMissingRolePermissions
+		}
+		return nil, "", nil, handleOktaResponseError(resp, err)
+	}
+
+	nextPage, err := nextPageFromLink(data.Links)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return data.ResourceSets, nextPage, &responseContext{OktaResponse: resp}, nil
+}
+
+func listResourceSetBindings(ctx context.Context, client *okta.Client, resourceSetID string, after string) ([]Role, string, *responseContext, error) {
+	reqUrl, err := url.JoinPath(apiPathListIamResourceSets, resourceSetID, "bindings")
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if after != "" {
+		reqUrl += "?" + url.Values{"after": []string{after}}.Encode()
+	}
+
+	var data ResourceSetsBindingsAPIData
+	resp, err := doRequest(ctx, client, http.MethodGet, reqUrl, nil, &data)
+	if err != nil {
+		return nil, "", nil, handleOktaResponseError(resp, err)
+	}
+
+	nextPage, err := nextPageFromLink(Link{Next: data.Links.Next})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return data.Roles, nextPage, &responseContext{OktaResponse: resp}, nil
+}
+
+func listBindingMembers(
+	ctx context.Context,
+	client *okta.Client,
+	resourceSetID string,
+	roleID string,
+	after string,
+) ([]MembersDetails, string, *responseContext, error) {
+	return readBindingMembers(ctx, client, doRequest, resourceSetID, roleID, after)
+}
+
+// listCurrentBindingMembers is listBindingMembers bypassing the response cache, so members added or removed moments
+// ago are seen.
+func listCurrentBindingMembers(
+	ctx context.Context,
+	client *okta.Client,
+	resourceSetID string,
+	roleID string,
+	after string,
+) ([]MembersDetails, string, *responseContext, error) {
+	return readBindingMembers(ctx, client, doUncachedRequest, resourceSetID, roleID, after)
+}
+
+func readBindingMembers(
+	ctx context.Context,
+	client *okta.Client,
+	do func(context.Context, *okta.Client, string, string, interface{}, interface{}) (*okta.Response, error),
+	resourceSetID string,
+	roleID string,
+	after string,
+) ([]MembersDetails, string, *responseContext, error) {
+	reqUrl, err := url.JoinPath(apiPathListIamResourceSets, resourceSetID, "bindings", roleID, "members")
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if after != "" {
+		reqUrl += "?" + url.Values{"after": []string{after}}.Encode()
+	}
+
+	var data BindingMembersAPIData
+	resp, err := do(ctx, client, http.MethodGet, reqUrl, nil, &data)
+	if err != nil {
+		return nil, "", nil, handleOktaResponseError(resp, err)
+	}
+
+	nextPage, err := nextPageFromLink(data.Links)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return data.Members, nextPage, &responseContext{OktaResponse: resp}, nil
+}
+
+// findBindingMember returns nil if the principal is not a member of the binding. Members are read past the response
+// cache, since adding or removing a member doesn't clear the cached member list.
+func findBindingMember(ctx context.Context, client *okta.Client, resourceSetID string, roleID string, principalID *v2.ResourceId) (*MembersDetails, error) {
+	after := ""
+	for {
+		members, nextPage, _, err := listCurrentBindingMembers(ctx, client, resourceSetID, roleID, after)
+		if err != nil {
+			return nil, fmt.Errorf("okta-connector: failed to list resource set binding members: %w", err)
+		}
+
+		for _, member := range members {
+			memberPrincipal, err := bindingMemberPrincipal(member)
+			if err != nil {
+				continue
+			}
+			if memberPrincipal.ResourceType == principalID.ResourceType && memberPrincipal.Resource == principalID.Resource {
+				return &member, nil
+			}
+		}
+
+		if nextPage == "" || nextPage == after {
+			return nil, nil
+		}
+		after = nextPage
+	}
+}
+
+// bindingMemberPrincipal resolves the user or group a binding member points to from its self link,
+// e.g. https://example.okta.com/api/v1/users/00ujp51vjgWd6ylZ6697.
+func bindingMemberPrincipal(member MembersDetails) (*v2.ResourceId, error) {
+	u, err := url.Parse(member.Links.Self.Href)
+	if err != nil {
+		return nil, err
+	}
+
+	collection, id := path.Split(strings.TrimSuffix(u.Path, "/"))
+	switch path.Base(collection) {
+	case "users":
+		return &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: id}, nil
+	case "groups":
+		return &v2.ResourceId{ResourceType: resourceTypeGroup.Id, Resource: id}, nil
+	default:
+		return nil, fmt.Errorf("okta-connector: unsupported resource set binding member: %s", member.Links.Self.Href)
+	}
+}
+
+func bindingID(resourceSetID string, roleID string) string {
+	return fmt.Sprintf("%s:%s", resourceSetID, roleID)
+}
+
+func parseBindingID(id string) (string, string, error) {
+	resourceSetID, roleID, ok := strings.Cut(id, ":")
+	if !ok || resourceSetID == "" || roleID == "" {
+		return "", "", fmt.Errorf("okta-connector: invalid resource set binding id: %s", id)
+	}
+
+	return resourceSetID, roleID, nil
+}
+
+// Create a new connector resource for an okta resource set.
+func resourceSetResource(ctx context.Context, resourceSet ResourceSets) (*v2.Resource, error) {
+	return sdkResource.NewResource(
+		resourceSet.Label,
+		resourceTypeResourceSet,
+		resourceSet.ID,
+		sdkResource.WithDescription(resourceSet.Description),
+		sdkResource.WithAnnotation(
+			&v2.V1Identifier{
+				Id: fmtResourceIdV1(resourceSet.ID),
+			},
+			&v2.ChildResourceType{ResourceTypeId: resourceTypeResourceSetBinding.Id},
+		),
+	)
+}
+
+// Create a new connector resource for the binding of a custom role to a resource set.
+func resourceSetBindingResource(ctx context.Context, resourceSetID *v2.ResourceId, role *CustomRole) (*v2.Resource, error) {
+	return sdkResource.NewResource(
+		role.Label,
+		resourceTypeResourceSetBinding,
+		bindingID(resourceSetID.Resource, role.ID),
+		sdkResource.WithParentResourceID(resourceSetID),
+		sdkResource.WithDescription(role.Description),
+	)
+}
+
+func bindingMemberGrant(resource *v2.Resource, principalID *v2.ResourceId) *v2.Grant {
+	pr := &v2.Resource{Id: principalID}
+	if principalID.ResourceType != resourceTypeGroup.Id {
+		return sdkGrant.NewGrant(resource, bindingMemberEntitlement, pr)
+	}
+
+	return sdkGrant.NewGrant(resource, bindingMemberEntitlement, pr,
+		sdkGrant.WithAnnotation(&v2.GrantExpandable{
+			EntitlementIds: []string{sdkEntitlement.NewEntitlementID(pr, groupMemberEntitlement)},
+		}),
+	)
+}
+
+func resourceSetBuilder(connector *Okta) *resourceSetResourceType {
+	return &resourceSetResourceType{
+		resourceType: resourceTypeResourceSet,
+		connector:    connector,
+	}
+}
+
+func resourceSetBindingBuilder(connector *Okta) *resourceSetBindingResourceType {
+	return &resourceSetBindingResourceType{
+		resourceType: resourceTypeResourceSetBinding,
+		connector:    connector,
+	}
+}
@@ -0,0 +1,84 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_bindingMemberPrincipal(t *testing.T) {
+	tests := []struct {
+		name         string
+		href         string
+		wantType     string
+		wantResource string
+		wantErr      bool
+	}{
+		{
+			name:         "user",
+			href:         "https://example.okta.com/api/v1/users/00ujp51vjgWd6ylZ6697",
+			wantType:     resourceTypeUser.Id,
+			wantResource: "00ujp51vjgWd6ylZ6697",
+		},
+		{
+			name:         "group",
+			href:         "https://example.okta.com/api/v1/groups/00gjp51vjgWd6ylZ6697/",
+			wantType:     resourceTypeGroup.Id,
+			wantResource: "00gjp51vjgWd6ylZ6697",
+		},
+		{
+			name:    "unsupported",
+			href:    "https://example.okta.com/api/v1/apps/0oajp51vjgWd6ylZ6697",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			member := MembersDetails{ID: "irb1"}
+			member.Links.Self.Href = tt.href
+
+			principal, err := bindingMemberPrincipal(member)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantType, principal.ResourceType)
+			require.Equal(t, tt.wantResource, principal.Resource)
+		})
+	}
+}
+
+func Test_parseBindingID(t *testing.T) {
+	resourceSetID, roleID, err := parseBindingID(bindingID("iamkuwy3gqcfNexfQ697", "cr0kuwv5507zJCtSy697"))
+	require.NoError(t, err)
+	require.Equal(t, "iamkuwy3gqcfNexfQ697", resourceSetID)
+	require.Equal(t, "cr0kuwv5507zJCtSy697", roleID)
+
+	_, _, err = parseBindingID("iamkuwy3gqcfNexfQ697")
+	require.Error(t, err)
+}
+
+func Test_findBindingMemberAfterChange(t *testing.T) {
+	members := `{"members": []}`
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/iam/resource-sets/iamrs1/bindings/cr1/members", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(members))
+	})
+	client := newTestClient(t, mux)
+	ctx := context.Background()
+	principalID := &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "00u1"}
+
+	// A sync read the members before the principal was added.
+	_, _, _, err := listBindingMembers(ctx, client, "iamrs1", "cr1", "")
+	require.NoError(t, err)
+	members = `{"members": [{"id": "irb1", "_links": {"self": {"href": "https://example.okta.com/api/v1/users/00u1"}}}]}`
+
+	member, err := findBindingMember(ctx, client, "iamrs1", "cr1", principalID)
+	require.NoError(t, err)
+	require.NotNil(t, member)
+	require.Equal(t, "irb1", member.ID)
+}
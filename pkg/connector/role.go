@@ -5,11 +5,12 @@ import (
 	"errors"
 	"net/http"
 	"net/url"
-
 	"strings"
+	"time"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
+	sdkEntitlement "github.com/conductorone/baton-sdk/pkg/types/entitlement"
 	sdkGrant "github.com/conductorone/baton-sdk/pkg/types/grant"
 	sdkResource "github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/okta/okta-sdk-golang/v2/okta"
@@ -38,10 +39,17 @@ const (
 	apiPathListAdministrators              = "/api/internal/administrators"
 	apiPathListIamCustomRoles              = "/api/v1/iam/roles"
 	apiPathListAllUsersWithRoleAssignments = "/api/v1/iam/assignees/users"
+	apiPathGroups                          = "/api/v1/groups"
+	roleTypeCustom                         = "CUSTOM"
+	roleAssignmentTypeUser                 = "USER"
+	roleAssignmentTypeGroup                = "GROUP"
+	roleStatusActive                       = "ACTIVE"
 	ContentType                            = "application/json"
 	NF                                     = -1
 )
 
+// Only individual assignments are checked here. Roles assigned through a group are emitted as
+// expandable group grants, so user access is computed from group membership.
 func userHasRoleAccess(administratorRoleFlags *administratorRoleFlags, resource *v2.Resource) bool {
 	roleName := strings.ReplaceAll(strings.ToLower(resource.Id.GetResource()), "_", "")
 	for _, role := range administratorRoleFlags.RolesFromIndividualAssignments {
@@ -50,13 +58,67 @@ func userHasRoleAccess(administratorRoleFlags *administratorRoleFlags, resource
 		}
 	}
 
-	for _, role := range administratorRoleFlags.RolesFromGroup {
-		if strings.ToLower(role) == roleName {
-			return true
+	return false
+}
+
+// roleAssignment returns the active assignment of a standard role with the given assignment type, or nil if there is none.
+// The roles of a user include those inherited from groups, which are reported with the GROUP assignment type.
+func roleAssignment(roles []*Roles, roleType string, assignmentType string) *Roles {
+	for _, role := range roles {
+		if role.Type == roleType && role.AssignmentType == assignmentType && role.Status == roleStatusActive {
+			return role
 		}
 	}
 
-	return false
+	return nil
+}
+
+// Role lookups for the same group are repeated for every role resource during a sync, so these are
+// served from the okta client response cache when it is enabled.
+func listGroupAssignedRoles(ctx context.Context, client *okta.Client, groupID string) ([]*Roles, error) {
+	reqUrl, err := url.JoinPath(apiPathGroups, groupID, "roles")
+	if err != nil {
+		return nil, err
+	}
+
+	return listAssignedRoles(ctx, client, reqUrl)
+}
+
+func listUserAssignedRoles(ctx context.Context, client *okta.Client, userID string) ([]*Roles, error) {
+	reqUrl, err := url.JoinPath(usersUrl, userID, "roles")
+	if err != nil {
+		return nil, err
+	}
+
+	return listAssignedRoles(ctx, client, reqUrl)
+}
+
+func listAssignedRoles(ctx context.Context, client *okta.Client, reqUrl string) ([]*Roles, error) {
+	return readAssignedRoles(ctx, client, doRequest, reqUrl)
+}
+
+// listCurrentAssignedRoles is listAssignedRoles bypassing the response cache, so roles assigned or removed moments
+// ago are seen.
+func listCurrentAssignedRoles(ctx context.Context, client *okta.Client, reqUrl string) ([]*Roles, error) {
+	return readAssignedRoles(ctx, client, doUncachedRequest, reqUrl)
+}
+
+func readAssignedRoles(
+	ctx context.Context,
+	client *okta.Client,
+	do func(context.Context, *okta.Client, string, string, interface{}, interface{}) (*okta.Response, error),
+	reqUrl string,
+) ([]*Roles, error) {
+	var roles []*Roles
+	resp, err := do(ctx, client, http.MethodGet, reqUrl, nil, &roles)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusForbidden {
+			return nil, errMissingRolePermissions
+		}
+		return nil, handleOktaResponseError(resp, err)
+	}
+
+	return roles, nil
 }
 
 func getOrgSettings(ctx context.Context, client *okta.Client, token *pagination.Token) (*okta.OrgSetting, *responseContext, error) {
@@ -136,6 +198,31 @@ func listAdministratorRoleFlags(
 	return adminFlags, respCtx, nil
 }
 
+func listUsersWithRoleAssignments(
+	ctx context.Context,
+	client *okta.Client,
+	token *pagination.Token,
+	after string,
+) ([]*RoleAssignee, string, *responseContext, error) {
+	reqUrl := apiPathListAllUsersWithRoleAssignments + queryParams(token.Size, after).String()
+
+	var data RoleAssigneesAPIData
+	resp, err := doRequest(ctx, client, http.MethodGet, reqUrl, nil, &data)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusForbidden {
+			return nil, "", nil, errMissingRolePermissions
+		}
+		return nil, "", nil, handleOktaResponseError(resp, err)
+	}
+
+	nextPage, err := nextPageFromLink(data.Links)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return data.Value, nextPage, &responseContext{OktaResponse: resp}, nil
+}
+
 func standardRoleFromType(roleType string) *okta.Role {
 	for _, standardRoleType := range standardRoleTypes {
 		if standardRoleType.Type == roleType {
@@ -178,12 +265,44 @@ func roleResource(ctx context.Context, role *okta.Role, ctype *v2.ResourceType)
 	)
 }
 
-func roleGrant(userID string, resource *v2.Resource) *v2.Grant {
+// roleAssignmentGrantMetadata records where a role grant comes from, so it can be traced back to the assignment in Okta,
+// along with the groups or apps the assignment is scoped to when they are known.
+func roleAssignmentGrantMetadata(role *Roles, targets *roleTargets) sdkGrant.GrantOption {
+	metadata := map[string]interface{}{
+		"assignment_type": role.AssignmentType,
+	}
+	if role.Id != "" {
+		metadata["assignment_id"] = role.Id
+	}
+	if role.Created != nil {
+		metadata["created"] = role.Created.Format(time.RFC3339)
+	}
+	roleTargetMetadata(metadata, targets)
+
+	return sdkGrant.WithGrantMetadata(metadata)
+}
+
+func roleGroupGrant(groupID string, resource *v2.Resource, opts ...sdkGrant.GrantOption) *v2.Grant {
+	gr := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeGroup.Id, Resource: groupID}}
+
+	opts = append(opts, sdkGrant.WithAnnotation(
+		&v2.V1Identifier{
+			Id: fmtGrantIdV1(V1MembershipEntitlementID(resource.Id.Resource), groupID),
+		},
+		&v2.GrantExpandable{
+			EntitlementIds: []string{sdkEntitlement.NewEntitlementID(gr, groupMemberEntitlement)},
+		},
+	))
+
+	return sdkGrant.NewGrant(resource, "assigned", gr, opts...)
+}
+
+func roleGrant(userID string, resource *v2.Resource, opts ...sdkGrant.GrantOption) *v2.Grant {
 	ur := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: userID}}
 
-	return sdkGrant.NewGrant(resource, "assigned", ur,
-		sdkGrant.WithAnnotation(&v2.V1Identifier{
-			Id: fmtGrantIdV1(V1MembershipEntitlementID(resource.Id.Resource), userID),
-		}),
-	)
+	opts = append(opts, sdkGrant.WithAnnotation(&v2.V1Identifier{
+		Id: fmtGrantIdV1(V1MembershipEntitlementID(resource.Id.Resource), userID),
+	}))
+
+	return sdkGrant.NewGrant(resource, "assigned", ur, opts...)
 }
@@ -0,0 +1,502 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	sdkEntitlement "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	sdkGrant "github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"go.uber.org/zap"
+)
+
+// Standard roles that can be scoped to specific groups or apps instead of all of them.
+// See: https://developer.okta.com/docs/reference/api/roles/#role-target-operations
+var (
+	groupTargetRoleTypes = []string{"USER_ADMIN", "HELP_DESK_ADMIN", "GROUP_MEMBERSHIP_ADMIN"}
+	appTargetRoleTypes   = []string{"APP_ADMIN"}
+)
+
+const (
+	roleTargetScopeOrg       = "ORG"
+	roleTargetScopeAllGroups = "ALL_GROUPS"
+	roleTargetScopeGroups    = "GROUPS"
+	roleTargetScopeAllApps   = "ALL_APPS"
+	roleTargetScopeApps      = "APPS"
+)
+
+// roleTargets is what a role assignment is scoped to. Groups and Apps are empty when the role covers all
+// of them, or when the targets are unknown because they came from the administrators endpoint.
+type roleTargets struct {
+	Scope  string
+	Groups []string
+	Apps   []*okta.CatalogApplication
+}
+
+func (t *roleTargets) hasGroup(groupID string) bool {
+	return slices.Contains(t.Groups, groupID)
+}
+
+func (t *roleTargets) hasApp(appID string) bool {
+	return slices.ContainsFunc(t.Apps, func(app *okta.CatalogApplication) bool {
+		return app.Id == appID
+	})
+}
+
+func (t *roleTargets) count() int {
+	return len(t.Groups) + len(t.Apps)
+}
+
+// listRoleTargets fetches the groups or apps a role assignment of a user or group is scoped to.
+func listRoleTargets(ctx context.Context, client *okta.Client, principalID *v2.ResourceId, role *Roles) (*roleTargets, error) {
+	switch {
+	case slices.Contains(groupTargetRoleTypes, role.Type):
+		groups, err := listRoleGroupTargets(ctx, client, principalID, role.Id)
+		if err != nil {
+			return nil, err
+		}
+		return groupRoleTargets(groups), nil
+	case slices.Contains(appTargetRoleTypes, role.Type):
+		apps, err := listRoleAppTargets(ctx, client, principalID, role.Id)
+		if err != nil {
+			return nil, err
+		}
+		return appRoleTargets(apps), nil
+	default:
+		return &roleTargets{Scope: roleTargetScopeOrg}, nil
+	}
+}
+
+// listCurrentRoleTargets is listRoleTargets bypassing the response cache, so targets added or removed moments ago
+// are seen.
+func listCurrentRoleTargets(ctx context.Context, client *okta.Client, principalID *v2.ResourceId, role *Roles) (*roleTargets, error) {
+	collection := "users"
+	if principalID.ResourceType == resourceTypeGroup.Id {
+		collection = "groups"
+	}
+
+	switch {
+	case slices.Contains(groupTargetRoleTypes, role.Type):
+		reqUrl, err := url.JoinPath("/api/v1", collection, principalID.Resource, "roles", role.Id, "targets", "groups")
+		if err != nil {
+			return nil, err
+		}
+		groups, resp, err := listUncached[*okta.Group](ctx, client, reqUrl)
+		if err != nil {
+			return nil, handleOktaResponseError(resp, err)
+		}
+		groupIDs := make([]string, 0, len(groups))
+		for _, group := range groups {
+			groupIDs = append(groupIDs, group.Id)
+		}
+		return groupRoleTargets(groupIDs), nil
+	case slices.Contains(appTargetRoleTypes, role.Type):
+		reqUrl, err := url.JoinPath("/api/v1", collection, principalID.Resource, "roles", role.Id, "targets", "catalog", "apps")
+		if err != nil {
+			return nil, err
+		}
+		apps, resp, err := listUncached[*okta.CatalogApplication](ctx, client, reqUrl)
+		if err != nil {
+			return nil, handleOktaResponseError(resp, err)
+		}
+		return appRoleTargets(apps), nil
+	default:
+		return &roleTargets{Scope: roleTargetScopeOrg}, nil
+	}
+}
+
+// A role without targets covers all groups or apps.
+func groupRoleTargets(groups []string) *roleTargets {
+	if len(groups) == 0 {
+		return &roleTargets{Scope: roleTargetScopeAllGroups}
+	}
+
+	return &roleTargets{Scope: roleTargetScopeGroups, Groups: groups}
+}
+
+func appRoleTargets(apps []*okta.CatalogApplication) *roleTargets {
+	if len(apps) == 0 {
+		return &roleTargets{Scope: roleTargetScopeAllApps}
+	}
+
+	return &roleTargets{Scope: roleTargetScopeApps, Apps: apps}
+}
+
+// roleTargetsFromFlags derives the scope of a role from the administrators endpoint flags. The endpoint
+// doesn't list the targets themselves.
+func roleTargetsFromFlags(flags *administratorRoleFlags, roleType string) *roleTargets {
+	forAll := map[string]bool{
+		"USER_ADMIN":             flags.ForAllUserAdminGroups,
+		"HELP_DESK_ADMIN":        flags.ForAllHelpDeskAdminGroups,
+		"GROUP_MEMBERSHIP_ADMIN": flags.ForAllGroupMembershipAdminGroups,
+		"APP_ADMIN":              flags.ForAllApps,
+	}
+
+	switch {
+	case slices.Contains(groupTargetRoleTypes, roleType):
+		if forAll[roleType] {
+			return &roleTargets{Scope: roleTargetScopeAllGroups}
+		}
+		return &roleTargets{Scope: roleTargetScopeGroups}
+	case slices.Contains(appTargetRoleTypes, roleType):
+		if forAll[roleType] {
+			return &roleTargets{Scope: roleTargetScopeAllApps}
+		}
+		return &roleTargets{Scope: roleTargetScopeApps}
+	default:
+		return &roleTargets{Scope: roleTargetScopeOrg}
+	}
+}
+
+func listRoleGroupTargets(ctx context.Context, client *okta.Client, principalID *v2.ResourceId, roleID string) ([]string, error) {
+	var groups []*okta.Group
+	var resp *okta.Response
+	var err error
+	switch principalID.ResourceType {
+	case resourceTypeUser.Id:
+		groups, resp, err = client.User.ListGroupTargetsForRole(ctx, principalID.Resource, roleID, nil)
+	case resourceTypeGroup.Id:
+		groups, resp, err = client.Group.ListGroupTargetsForGroupRole(ctx, principalID.Resource, roleID, nil)
+	default:
+		return nil, fmt.Errorf("okta-connectorv2: unexpected role principal type: %s", principalID.ResourceType)
+	}
+	if err != nil {
+		return nil, handleOktaResponseError(resp, err)
+	}
+
+	for resp.HasNextPage() {
+		var nextGroups []*okta.Group
+		resp, err = resp.Next(ctx, &nextGroups)
+		if err != nil {
+			return nil, handleOktaResponseError(resp, err)
+		}
+		groups = append(groups, nextGroups...)
+	}
+
+	groupIDs := make([]string, 0, len(groups))
+	for _, group := range groups {
+		groupIDs = append(groupIDs, group.Id)
+	}
+
+	return groupIDs, nil
+}
+
+// App targets are either a whole catalog app (e.g. every salesforce instance), or a single app instance
+// in which case the id is set.
+func listRoleAppTargets(ctx context.Context, client *okta.Client, principalID *v2.ResourceId, roleID string) ([]*okta.CatalogApplication, error) {
+	var apps []*okta.CatalogApplication
+	var resp *okta.Response
+	var err error
+	switch principalID.ResourceType {
+	case resourceTypeUser.Id:
+		apps, resp, err = client.User.ListApplicationTargetsForApplicationAdministratorRoleForUser(ctx, principalID.Resource, roleID, nil)
+	case resourceTypeGroup.Id:
+		apps, resp, err = client.Group.ListApplicationTargetsForApplicationAdministratorRoleForGroup(ctx, principalID.Resource, roleID, nil)
+	default:
+		return nil, fmt.Errorf("okta-connectorv2: unexpected role principal type: %s", principalID.ResourceType)
+	}
+	if err != nil {
+		return nil, handleOktaResponseError(resp, err)
+	}
+
+	for resp.HasNextPage() {
+		var nextApps []*okta.CatalogApplication
+		resp, err = resp.Next(ctx, &nextApps)
+		if err != nil {
+			return nil, handleOktaResponseError(resp, err)
+		}
+		apps = append(apps, nextApps...)
+	}
+
+	return apps, nil
+}
+
+func roleTargetMetadata(metadata map[string]interface{}, targets *roleTargets) {
+	if targets == nil {
+		return
+	}
+
+	metadata["target_scope"] = targets.Scope
+	if len(targets.Groups) > 0 {
+		groups := make([]interface{}, 0, len(targets.Groups))
+		for _, groupID := range targets.Groups {
+			groups = append(groups, groupID)
+		}
+		metadata["target_groups"] = groups
+	}
+	if len(targets.Apps) > 0 {
+		apps := make([]interface{}, 0, len(targets.Apps))
+		for _, app := range targets.Apps {
+			if app.Id != "" {
+				apps = append(apps, app.Id)
+			} else {
+				apps = append(apps, app.Name)
+			}
+		}
+		metadata["target_apps"] = apps
+	}
+}
+
+// roleTargetEntitlementSlug is the slug of the entitlement on a group or app that grants a role scoped to it,
+// e.g. help_desk_admin.
+func roleTargetEntitlementSlug(roleType string) string {
+	return strings.ToLower(roleType)
+}
+
+func roleTypeFromTargetEntitlementSlug(slug string) string {
+	return strings.ToUpper(slug)
+}
+
+func roleTargetEntitlements(resource *v2.Resource, roleTypes []string) []*v2.Entitlement {
+	rv := make([]*v2.Entitlement, 0, len(roleTypes))
+	for _, roleType := range roleTypes {
+		role := standardRoleFromType(roleType)
+		rv = append(rv, sdkEntitlement.NewAssignmentEntitlement(resource, roleTargetEntitlementSlug(roleType),
+			sdkEntitlement.WithDisplayName(fmt.Sprintf("%s %s", resource.DisplayName, role.Label)),
+			sdkEntitlement.WithDescription(fmt.Sprintf("Has the %s role in Okta scoped to %s", role.Label, resource.DisplayName)),
+			sdkEntitlement.WithGrantableTo(resourceTypeUser, resourceTypeGroup),
+		))
+	}
+
+	return rv
+}
+
+// roleTargetGrants returns the grants of the role target entitlements on the groups and apps a role assignment
+// is scoped to. Catalog app targets cover apps that may not exist yet, so only app instance targets are granted.
+func roleTargetGrants(principalID *v2.ResourceId, role *Roles, targets *roleTargets) []*v2.Grant {
+	slug := roleTargetEntitlementSlug(role.Type)
+	var rv []*v2.Grant
+	for _, groupID := range targets.Groups {
+		gr := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeGroup.Id, Resource: groupID}}
+		rv = append(rv, roleTargetGrant(gr, slug, principalID, role))
+	}
+	for _, app := range targets.Apps {
+		if app.Id == "" {
+			continue
+		}
+		ar := &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeApp.Id, Resource: app.Id}}
+		rv = append(rv, roleTargetGrant(ar, slug, principalID, role))
+	}
+
+	return rv
+}
+
+func roleTargetGrant(resource *v2.Resource, slug string, principalID *v2.ResourceId, role *Roles) *v2.Grant {
+	pr := &v2.Resource{Id: principalID}
+	opts := []sdkGrant.GrantOption{roleAssignmentGrantMetadata(role, nil)}
+	if principalID.ResourceType == resourceTypeGroup.Id {
+		opts = append(opts, sdkGrant.WithAnnotation(&v2.GrantExpandable{
+			EntitlementIds: []string{sdkEntitlement.NewEntitlementID(pr, groupMemberEntitlement)},
+		}))
+	}
+
+	return sdkGrant.NewGrant(resource, slug, pr, opts...)
+}
+
+// roleTarget is a group or app a role assignment can be scoped to. AppName is the catalog name of the app,
+// which Okta needs alongside the app instance id.
+type roleTarget struct {
+	GroupID string
+	AppID   string
+	AppName string
+}
+
+func (t *roleTarget) in(targets *roleTargets) bool {
+	if t.GroupID != "" {
+		return targets.hasGroup(t.GroupID)
+	}
+
+	return targets.hasApp(t.AppID)
+}
+
+// principalRoleAssignment returns the individual assignment of a standard role to a user or group, or nil if there is none.
+// Roles are read past the response cache, since assigning or removing a role doesn't clear the cached roles.
+func principalRoleAssignment(ctx context.Context, client *okta.Client, principalID *v2.ResourceId, roleType string) (*Roles, error) {
+	var reqUrl string
+	var assignmentType string
+	var err error
+	switch principalID.ResourceType {
+	case resourceTypeUser.Id:
+		reqUrl, err = url.JoinPath(usersUrl, principalID.Resource, "roles")
+		assignmentType = roleAssignmentTypeUser
+	case resourceTypeGroup.Id:
+		reqUrl, err = url.JoinPath(apiPathGroups, principalID.Resource, "roles")
+		assignmentType = roleAssignmentTypeGroup
+	default:
+		return nil, fmt.Errorf("okta-connector: only users or groups can be granted role membership")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := listCurrentAssignedRoles(ctx, client, reqUrl)
+	if err != nil {
+		return nil, fmt.Errorf("okta-connector: failed to get roles: %w", err)
+	}
+
+	return roleAssignment(roles, roleType, assignmentType), nil
+}
+
+// grantRoleTarget scopes a standard role of a user or group to a group or app. When the principal doesn't
+// have the role yet it is assigned first, which Okta requires before targets can be added.
+func grantRoleTarget(ctx context.Context, client *okta.Client, principalID *v2.ResourceId, roleType string, target *roleTarget) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	role, err := principalRoleAssignment(ctx, client, principalID, roleType)
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := false
+	if role != nil {
+		targets, err := listCurrentRoleTargets(ctx, client, principalID, role)
+		if err != nil {
+			return nil, fmt.Errorf("okta-connector: failed to list role targets: %w", err)
+		}
+
+		// Adding a target to a role that covers all groups or apps would narrow it down to that target.
+		if targets.Scope == roleTargetScopeAllGroups || targets.Scope == roleTargetScopeAllApps || target.in(targets) {
+			l.Warn(
+				"okta-connector: The role specified is already assigned for the target",
+				zap.String("principal_id", principalID.String()),
+				zap.String("principal_type", principalID.ResourceType),
+				zap.String("role_type", roleType),
+				zap.String("target_scope", targets.Scope),
+			)
+			return annotations.New(&v2.GrantAlreadyExists{}), nil
+		}
+	} else {
+		var createdRole *okta.Role
+		var response *okta.Response
+		if principalID.ResourceType == resourceTypeUser.Id {
+			createdRole, response, err = client.User.AssignRoleToUser(ctx, principalID.Resource, okta.AssignRoleRequest{Type: roleType}, nil)
+		} else {
+			createdRole, response, err = client.Group.AssignRoleToGroup(ctx, principalID.Resource, okta.AssignRoleRequest{Type: roleType}, nil)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("okta-connector: failed to assign role: %w", handleOktaResponseError(response, err))
+		}
+
+		role = &Roles{Id: createdRole.Id, Type: createdRole.Type}
+		assigned = true
+	}
+
+	response, err := addRoleTarget(ctx, client, principalID, role.Id, target)
+	if err != nil {
+		err = fmt.Errorf("okta-connector: failed to add role target: %w", handleOktaResponseError(response, err))
+		// Without a target the role just assigned covers all groups or apps, so it can't be left behind.
+		if assigned {
+			response, rmErr := removePrincipalRole(ctx, client, principalID, role.Id)
+			if rmErr != nil {
+				return nil, errors.Join(err, fmt.Errorf("okta-connector: failed to remove role %s assigned without a target: %w",
+					role.Id, handleOktaResponseError(response, rmErr)))
+			}
+		}
+		return nil, err
+	}
+
+	l.Warn("Role target has been added.",
+		zap.String("principal_id", principalID.Resource),
+		zap.String("role_id", role.Id),
+		zap.String("role_type", roleType),
+		zap.String("group_id", target.GroupID),
+		zap.String("app_id", target.AppID),
+		zap.String("Status", response.Status),
+	)
+
+	return nil, nil
+}
+
+// revokeRoleTarget removes a group or app from the targets of a standard role of a user or group. Okta doesn't
+// allow removing the last target, since that would widen the role to all groups or apps, so the role
+// assignment is removed instead.
+func revokeRoleTarget(ctx context.Context, client *okta.Client, principalID *v2.ResourceId, roleType string, target *roleTarget) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	role, err := principalRoleAssignment(ctx, client, principalID, roleType)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets *roleTargets
+	if role != nil {
+		targets, err = listCurrentRoleTargets(ctx, client, principalID, role)
+		if err != nil {
+			return nil, fmt.Errorf("okta-connector: failed to list role targets: %w", err)
+		}
+	}
+
+	if role == nil || !target.in(targets) {
+		l.Warn(
+			"okta-connector: role is not assigned for the target",
+			zap.String("principal_id", principalID.String()),
+			zap.String("principal_type", principalID.ResourceType),
+			zap.String("role_type", roleType),
+		)
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
+
+	var response *okta.Response
+	if targets.count() == 1 {
+		response, err = removePrincipalRole(ctx, client, principalID, role.Id)
+		if err != nil {
+			return nil, fmt.Errorf("okta-connector: failed to remove role: %w", handleOktaResponseError(response, err))
+		}
+	} else {
+		response, err = removeRoleTarget(ctx, client, principalID, role.Id, target)
+		if err != nil {
+			return nil, fmt.Errorf("okta-connector: failed to remove role target: %w", handleOktaResponseError(response, err))
+		}
+	}
+
+	l.Warn("Role target has been revoked",
+		zap.String("principal_id", principalID.Resource),
+		zap.String("role_id", role.Id),
+		zap.String("role_type", roleType),
+		zap.String("Status", response.Status),
+	)
+
+	return nil, nil
+}
+
+// removePrincipalRole removes a role assignment from a user or group.
+func removePrincipalRole(ctx context.Context, client *okta.Client, principalID *v2.ResourceId, roleID string) (*okta.Response, error) {
+	if principalID.ResourceType == resourceTypeUser.Id {
+		return client.User.RemoveRoleFromUser(ctx, principalID.Resource, roleID)
+	}
+
+	return client.Group.RemoveRoleFromGroup(ctx, principalID.Resource, roleID)
+}
+
+func addRoleTarget(ctx context.Context, client *okta.Client, principalID *v2.ResourceId, roleID string, target *roleTarget) (*okta.Response, error) {
+	isUser := principalID.ResourceType == resourceTypeUser.Id
+	switch {
+	case target.GroupID != "" && isUser:
+		return client.User.AddGroupTargetToRole(ctx, principalID.Resource, roleID, target.GroupID)
+	case target.GroupID != "":
+		return client.Group.AddGroupTargetToGroupAdministratorRoleForGroup(ctx, principalID.Resource, roleID, target.GroupID)
+	case isUser:
+		return client.User.AddApplicationTargetToAppAdminRoleForUser(ctx, principalID.Resource, roleID, target.AppName, target.AppID)
+	default:
+		return client.Group.AddApplicationInstanceTargetToAppAdminRoleGivenToGroup(ctx, principalID.Resource, roleID, target.AppName, target.AppID)
+	}
+}
+
+func removeRoleTarget(ctx context.Context, client *okta.Client, principalID *v2.ResourceId, roleID string, target *roleTarget) (*okta.Response, error) {
+	isUser := principalID.ResourceType == resourceTypeUser.Id
+	switch {
+	case target.GroupID != "" && isUser:
+		return client.User.RemoveGroupTargetFromRole(ctx, principalID.Resource, roleID, target.GroupID)
+	case target.GroupID != "":
+		return client.Group.RemoveGroupTargetFromGroupAdministratorRoleGivenToGroup(ctx, principalID.Resource, roleID, target.GroupID)
+	case isUser:
+		return client.User.RemoveApplicationTargetFromAdministratorRoleForUser(ctx, principalID.Resource, roleID, target.AppName, target.AppID)
+	default:
+		return client.Group.RemoveApplicationTargetFromAdministratorRoleGivenToGroup(ctx, principalID.Resource, roleID, target.AppName, target.AppID)
+	}
+}
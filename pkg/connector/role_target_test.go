@@ -0,0 +1,106 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_roleTargetsFromFlags(t *testing.T) {
+	flags := &administratorRoleFlags{
+		UserId:                    "00u1",
+		ForAllApps:                true,
+		ForAllHelpDeskAdminGroups: false,
+	}
+
+	require.Equal(t, roleTargetScopeAllApps, roleTargetsFromFlags(flags, "APP_ADMIN").Scope)
+	require.Equal(t, roleTargetScopeGroups, roleTargetsFromFlags(flags, "HELP_DESK_ADMIN").Scope)
+	require.Equal(t, roleTargetScopeOrg, roleTargetsFromFlags(flags, "SUPER_ADMIN").Scope)
+}
+
+func Test_roleTargetGrants(t *testing.T) {
+	principalID := &v2.ResourceId{ResourceType: resourceTypeGroup.Id, Resource: "00g1"}
+	role := &Roles{Id: "ra1", Type: "APP_ADMIN", AssignmentType: roleAssignmentTypeGroup}
+	targets := &roleTargets{
+		Scope: roleTargetScopeApps,
+		Apps: []*okta.CatalogApplication{
+			{Name: "salesforce"},
+			{Name: "salesforce", Id: "0oa1"},
+		},
+	}
+
+	grants := roleTargetGrants(principalID, role, targets)
+	require.Len(t, grants, 1)
+	require.Equal(t, "app:0oa1:app_admin", grants[0].Entitlement.Id)
+	require.Equal(t, principalID.Resource, grants[0].Principal.Id.Resource)
+
+	metadata := map[string]interface{}{}
+	roleTargetMetadata(metadata, targets)
+	require.Equal(t, roleTargetScopeApps, metadata["target_scope"])
+	require.Equal(t, []interface{}{"salesforce", "0oa1"}, metadata["target_apps"])
+}
+
+func Test_roleTypeFromTargetEntitlementSlug(t *testing.T) {
+	for _, roleType := range slices.Concat(groupTargetRoleTypes, appTargetRoleTypes) {
+		require.Equal(t, roleType, roleTypeFromTargetEntitlementSlug(roleTargetEntitlementSlug(roleType)))
+	}
+}
+
+func Test_grantRoleTargetRemovesRoleWithoutTarget(t *testing.T) {
+	var removed []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/users/00u1/roles", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[]`))
+	})
+	mux.HandleFunc("POST /api/v1/users/00u1/roles", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id": "ra1", "type": "HELP_DESK_ADMIN", "status": "ACTIVE"}`))
+	})
+	mux.HandleFunc("PUT /api/v1/users/00u1/roles/ra1/targets/groups/00g1", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"errorCode": "E0000001", "errorSummary": "Api validation failed"}`))
+	})
+	mux.HandleFunc("DELETE /api/v1/users/00u1/roles/{roleID}", func(w http.ResponseWriter, r *http.Request) {
+		removed = append(removed, r.PathValue("roleID"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	client := newTestClient(t, mux)
+
+	principalID := &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "00u1"}
+	_, err := grantRoleTarget(context.Background(), client, principalID, "HELP_DESK_ADMIN", &roleTarget{GroupID: "00g1"})
+	require.ErrorContains(t, err, "failed to add role target")
+	require.Equal(t, []string{"ra1"}, removed)
+}
+
+func Test_revokeRoleTargetAfterGrant(t *testing.T) {
+	roles := `[]`
+	var removed []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/users/00u1/roles", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(roles))
+	})
+	mux.HandleFunc("GET /api/v1/users/00u1/roles/ra1/targets/groups", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"id": "00g1"}, {"id": "00g2"}]`))
+	})
+	mux.HandleFunc("DELETE /api/v1/users/00u1/roles/ra1/targets/groups/{groupID}", func(w http.ResponseWriter, r *http.Request) {
+		removed = append(removed, r.PathValue("groupID"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	client := newTestClient(t, mux)
+	ctx := context.Background()
+
+	// A sync read the roles before the role was granted.
+	_, err := listUserAssignedRoles(ctx, client, "00u1")
+	require.NoError(t, err)
+	roles = `[{"id": "ra1", "type": "HELP_DESK_ADMIN", "status": "ACTIVE", "assignmentType": "USER"}]`
+
+	principalID := &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "00u1"}
+	annos, err := revokeRoleTarget(ctx, client, principalID, "HELP_DESK_ADMIN", &roleTarget{GroupID: "00g1"})
+	require.NoError(t, err)
+	require.Empty(t, annos)
+	require.Equal(t, []string{"00g1"}, removed)
+}
@@ -8,12 +8,10 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
-	"time"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
-	"github.com/conductorone/baton-sdk/pkg/crypto"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
 	"github.com/conductorone/baton-sdk/pkg/ratelimit"
 	"github.com/conductorone/baton-sdk/pkg/types/resource"
@@ -22,10 +20,15 @@ import (
 	"github.com/okta/okta-sdk-golang/v2/okta"
 	"github.com/okta/okta-sdk-golang/v2/okta/query"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
 	usersUrl = "/api/v1/users"
+
+	// Okta error code of failed API validation, such as creating a user with a login that is taken.
+	apiValidationFailed = "E0000001"
 )
 
 const (
@@ -67,7 +70,7 @@ func (o *userResourceType) List(
 	var rv []*v2.Resource
 	qp := queryParams(token.Size, page)
 
-	users, respCtx, err := listUsers(ctx, o.connector.client, token, qp)
+	users, respCtx, err := listUsers(ctx, o.connector.client, token, qp, o.connector.syncIdentityProviders)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list users: %w", err)
 	}
@@ -86,7 +89,7 @@ func (o *userResourceType) List(
 		if !shouldIncludeOktaUser(user, o.emailFilters) {
 			continue
 		}
-		resource, err := userResource(ctx, user, o.connector.skipSecondaryEmails)
+		resource, err := o.connector.userResource(ctx, user)
 		if err != nil {
 			return nil, "", nil, err
 		}
@@ -144,12 +147,23 @@ func (o *userResourceType) Entitlements(
 	return nil, "", nil, nil
 }
 
+// Users only have grants when authenticators are synced, their factor enrollments are emitted as grants on the
+// authenticator enrolled entitlements.
 func (o *userResourceType) Grants(
 	ctx context.Context,
 	resource *v2.Resource,
 	token *pagination.Token,
 ) ([]*v2.Grant, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+	if !o.connector.syncAuthenticators {
+		return nil, "", nil, nil
+	}
+
+	factors, err := listUserFactors(ctx, o.connector.client, resource.Id.GetResource())
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list user factors: %w", err)
+	}
+
+	return authenticatorEnrollmentGrants(resource, factors), "", nil, nil
 }
 
 func userName(user *okta.User) (string, string) {
@@ -167,7 +181,8 @@ func userName(user *okta.User) (string, string) {
 	return firstName, lastName
 }
 
-func listUsers(ctx context.Context, client *okta.Client, token *pagination.Token, qp *query.Params) ([]*okta.User, *responseContext, error) {
+// listUsers omits the user credentials unless includeCredentials is set, as a performance optimization.
+func listUsers(ctx context.Context, client *okta.Client, token *pagination.Token, qp *query.Params, includeCredentials bool) ([]*okta.User, *responseContext, error) {
 	if qp.Search == "" {
 		qp.Search = "status pr" // ListUsers doesn't get deactivated users by default. this should fetch them all
 	}
@@ -182,21 +197,18 @@ func listUsers(ctx context.Context, client *okta.Client, token *pagination.Token
 		return nil, nil, err
 	}
 
-	// Using okta-response="omitCredentials,omitCredentialsLinks,omitTransitioningToStatus" in the content type header omits
-	// the credentials, credentials links, and `transitioningToStatus` field from the response which applies performance optimization.
-	// https://developer.okta.com/docs/api/openapi/okta-management/management/tag/User/#tag/User/operation/listUsers!in=header&path=Content-Type&t=request
 	oktaUsers := make([]*okta.User, 0)
 	rq := client.CloneRequestExecutor()
 	req, err := rq.
 		WithAccept(ContentType).
-		WithContentType(`application/json; okta-response="omitCredentials,omitCredentialsLinks,omitTransitioningToStatus"`).
+		WithContentType(userResponseContentType(includeCredentials)).
 		NewRequest(http.MethodGet, reqUrl.String(), nil)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// Need to set content type here because the response was still including the credentials when setting it with WithContentType above
-	req.Header.Set("Content-Type", `application/json; okta-response="omitCredentials,omitCredentialsLinks,omitTransitioningToStatus"`)
+	req.Header.Set("Content-Type", userResponseContentType(includeCredentials))
 
 	resp, err := rq.Do(ctx, req, &oktaUsers)
 	if err != nil {
@@ -210,30 +222,96 @@ func listUsers(ctx context.Context, client *okta.Client, token *pagination.Token
 	return oktaUsers, respCtx, nil
 }
 
-func ciamUserBuilder(connector *Okta) *userResourceType {
+// Using okta-response="omitCredentials,omitCredentialsLinks,omitTransitioningToStatus" in the content type header omits
+// the credentials, credentials links, and `transitioningToStatus` field from the response which applies performance optimization.
+// https://developer.okta.com/docs/api/openapi/okta-management/management/tag/User/#tag/User/operation/listUsers!in=header&path=Content-Type&t=request
+func userResponseContentType(includeCredentials bool) string {
+	if includeCredentials {
+		return `application/json; okta-response="omitCredentialsLinks,omitTransitioningToStatus"`
+	}
+
+	return `application/json; okta-response="omitCredentials,omitCredentialsLinks,omitTransitioningToStatus"`
+}
+
+func lowerEmailDomains(emailDomains []string) []string {
 	var loweredFilters []string
-	for _, ef := range connector.ciamConfig.EmailDomains {
+	for _, ef := range emailDomains {
 		loweredFilters = append(loweredFilters, strings.ToLower(ef))
 	}
+	return loweredFilters
+}
+
+func ciamUserBuilder(connector *Okta) *userResourceType {
+	resourceType := resourceTypeUser
+	if connector.syncAuthenticators {
+		resourceType = resourceTypeUserWithGrants
+	}
+
 	return &userResourceType{
-		resourceType: resourceTypeUser,
-		emailFilters: loweredFilters,
+		resourceType: resourceType,
+		emailFilters: lowerEmailDomains(connector.ciamConfig.EmailDomains),
 		connector:    connector,
 	}
 }
 
+// userResource creates the connector resource for a user, with its user type and the account type from the
+// configured account type rules. Admin users are flagged when they have no phishing-resistant factor, so that every
+// resource of the user agrees.
+func (o *Okta) userResource(ctx context.Context, user *okta.User) (*v2.Resource, error) {
+	// Okta can return a user without a profile, and both the flag below and the user resource need one.
+	if user.Profile == nil {
+		user.Profile = &okta.UserProfile{}
+	}
+
+	userType, err := o.getUserType(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	isAdmin, err := o.isAdminUser(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	if isAdmin {
+		// Admins are checked for a phishing-resistant factor whether or not authenticators are synced.
+		factors, err := listUserFactors(ctx, o.client, user.Id)
+		if err != nil {
+			ctxzap.Extract(ctx).Warn("okta-connectorv2: failed to list admin user factors", zap.String("user_id", user.Id), zap.Error(err))
+		} else {
+			(*user.Profile)[profileMissingPhishingResistantFactor] = !hasPhishingResistantFactor(factors)
+		}
+	}
+
+	accountType := userAccountType(o.accountTypeRules, userType, *user.Profile)
+
+	return userResource(ctx, user, o.skipSecondaryEmails, userType, accountType)
+}
+
 // Create a new connector resource for a okta user.
-func userResource(ctx context.Context, user *okta.User, skipSecondaryEmails bool) (*v2.Resource, error) {
+func userResource(
+	ctx context.Context,
+	user *okta.User,
+	skipSecondaryEmails bool,
+	userType *okta.UserType,
+	accountType v2.UserTrait_AccountType,
+) (*v2.Resource, error) {
 	firstName, lastName := userName(user)
 
 	oktaProfile := *user.Profile
 	oktaProfile["c1_okta_raw_user_status"] = user.Status
+	if userType != nil {
+		oktaProfile["c1_okta_user_type_id"] = userType.Id
+		oktaProfile["c1_okta_user_type"] = userType.Name
+	}
+	// Credentials are omitted from user listings unless identity providers are synced.
+	if user.Credentials != nil && user.Credentials.Provider != nil {
+		oktaProfile["c1_okta_credential_provider_type"] = user.Credentials.Provider.Type
+		oktaProfile["c1_okta_credential_provider_name"] = user.Credentials.Provider.Name
+	}
 
 	options := []resource.UserTraitOption{
 		resource.WithUserProfile(oktaProfile),
-		// TODO?: use the user types API to figure out the account type
-		// https://developer.okta.com/docs/reference/api/user-types/
-		// resource.WithAccountType(v2.UserTrait_ACCOUNT_TYPE_UNSPECIFIED),
+		resource.WithAccountType(accountType),
 	}
 
 	displayName, ok := oktaProfile["displayName"].(string)
@@ -329,37 +407,53 @@ func (r *userResourceType) CreateAccount(
 	annotations.Annotations,
 	error,
 ) {
-	userProfile, err := getUserProfile(accountInfo)
+	creds, err := r.connector.getCredentialOption(ctx, credentialOptions)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	creds, err := getCredentialOption(credentialOptions)
+	params, err := getAccountCreationQueryParams(accountInfo, credentialOptions)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	params, err := getAccountCreationQueryParams(accountInfo, credentialOptions)
+	// Without a user type Okta creates the user with the default type.
+	var userType, createUserType *okta.UserType
+	if userTypeName, ok := accountInfo.Profile.AsMap()["user_type"].(string); ok && userTypeName != "" {
+		userType, err = r.connector.findUserType(ctx, userTypeName)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		createUserType = &okta.UserType{Id: userType.Id}
+	}
+
+	userProfile, err := r.connector.getUserProfile(ctx, accountInfo, userType)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	err = r.checkEmailDomainPolicy(ctx, accountInfo, userProfile)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
 	user, response, err := r.connector.client.User.CreateUser(ctx, okta.CreateUserRequest{
-		Profile: userProfile,
-		Type: &okta.UserType{
-			Created:   ToPtr(time.Now()),
-			CreatedBy: "ConductorOne",
-		},
+		Profile:     userProfile,
+		Type:        createUserType,
 		Credentials: creds,
 	}, params)
 	if err != nil {
-		return nil, nil, nil, err
+		if isLoginAlreadyExistsError(err) {
+			return r.existingAccount(ctx, userProfile)
+		}
+		return nil, nil, nil, fmt.Errorf("okta-connector: failed to create user: %w", handleOktaResponseError(response, err))
 	}
-	if response.StatusCode != http.StatusOK {
-		return nil, nil, nil, fmt.Errorf("okta-connectorv2: failed to create user: %s", response.Status)
+	// Okta answers with 200 when the user is created, but any 2xx without a user means it wasn't.
+	if user == nil || user.Id == "" {
+		return nil, nil, nil, fmt.Errorf("okta-connector: failed to create user: okta returned %s without a user", response.Status)
 	}
 
-	userResource, err := userResource(ctx, user, r.connector.skipSecondaryEmails)
+	userResource, err := r.connector.userResource(ctx, user)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -367,10 +461,121 @@ func (r *userResourceType) CreateAccount(
 		Resource: userResource,
 	}
 
-	return car, nil, nil, nil
+	var plaintexts []*v2.PlaintextData
+	if creds != nil && creds.Password != nil {
+		plaintexts = append(plaintexts, passwordPlaintextData(creds.Password.Value))
+	}
+
+	return car, plaintexts, nil, nil
+}
+
+// checkEmailDomainPolicy refuses to create users whose email or login is outside the CIAM email domains, since
+// they would never be synced. Account creation can explicitly allow it.
+func (r *userResourceType) checkEmailDomainPolicy(ctx context.Context, accountInfo *v2.AccountInfo, userProfile *okta.UserProfile) error {
+	if len(r.emailFilters) == 0 {
+		return nil
+	}
+
+	violation := emailDomainPolicyViolation(userProfile, r.emailFilters)
+	if violation == "" {
+		return nil
+	}
+
+	if allow, ok := accountInfo.Profile.AsMap()[accountFieldAllowOutsideEmailDomains]; ok && allow != nil {
+		allowed, err := convertSchemaScalar(userSchemaTypeBoolean, allow)
+		if err != nil {
+			return fmt.Errorf("okta-connector: invalid value for %s: %w", accountFieldAllowOutsideEmailDomains, err)
+		}
+		if allowed.(bool) {
+			ctxzap.Extract(ctx).Warn("okta-connector: creating a user outside the CIAM email domains, they won't be synced",
+				zap.String("violation", violation),
+			)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("okta-connector: %s is outside the CIAM email domains %s, the user would not be synced. Set %s to create them anyway",
+		violation, strings.Join(r.emailFilters, ", "), accountFieldAllowOutsideEmailDomains)
+}
+
+// emailDomainPolicyViolation returns which of the email and the login of a new user is outside the email domains,
+// or an empty string if both are inside. Logins that aren't email addresses don't have a domain to check.
+func emailDomainPolicyViolation(userProfile *okta.UserProfile, emailDomainFilters []string) string {
+	email, _ := (*userProfile)["email"].(string)
+	if !shouldIncludeUserByEmails([]string{email}, emailDomainFilters) {
+		return fmt.Sprintf("email %s", email)
+	}
+
+	login, _ := (*userProfile)["login"].(string)
+	if strings.Contains(login, "@") && !shouldIncludeUserByEmails([]string{login}, emailDomainFilters) {
+		return fmt.Sprintf("login %s", login)
+	}
+
+	return ""
+}
+
+// existingAccount returns the user that already has the login of an account that is being created, so retried
+// account creation succeeds. It fails when the existing user has a different email, since that is another person.
+func (r *userResourceType) existingAccount(
+	ctx context.Context,
+	userProfile *okta.UserProfile,
+) (
+	connectorbuilder.CreateAccountResponse,
+	[]*v2.PlaintextData,
+	annotations.Annotations,
+	error,
+) {
+	login, _ := (*userProfile)["login"].(string)
+	email, _ := (*userProfile)["email"].(string)
+
+	user, _, err := getCurrentUser(ctx, r.connector.client, login)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("okta-connector: user with login %s already exists, failed to get it: %w", login, err)
+	}
+
+	existingEmail := ""
+	if user.Profile != nil {
+		existingEmail, _ = (*user.Profile)["email"].(string)
+	}
+	if !strings.EqualFold(existingEmail, email) {
+		return nil, nil, nil, fmt.Errorf("okta-connector: user with login %s already exists with a different email", login)
+	}
+
+	ctxzap.Extract(ctx).Warn("okta-connector: user already exists, returning the existing user",
+		zap.String("user_id", user.Id),
+		zap.String("login", login),
+	)
+
+	userResource, err := r.connector.userResource(ctx, user)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// No password is returned, the existing user keeps their credentials.
+	return &v2.CreateAccountResponse_SuccessResult{
+		Resource: userResource,
+	}, nil, nil, nil
+}
+
+// isLoginAlreadyExistsError reports whether creating a user failed because another user has the same login, which
+// Okta reports as an E0000001 validation error with a cause on the login.
+func isLoginAlreadyExistsError(err error) bool {
+	var oktaErr *okta.Error
+	if !errors.As(err, &oktaErr) || oktaErr.ErrorCode != apiValidationFailed {
+		return false
+	}
+
+	for _, cause := range oktaErr.ErrorCauses {
+		summary, _ := cause["errorSummary"].(string)
+		if strings.HasPrefix(summary, "login:") && strings.Contains(summary, "already exists") {
+			return true
+		}
+	}
+
+	return false
 }
 
-func getCredentialOption(credentialOptions *v2.CredentialOptions) (*okta.UserCredentials, error) {
+func (o *Okta) getCredentialOption(ctx context.Context, credentialOptions *v2.CredentialOptions) (*okta.UserCredentials, error) {
 	if credentialOptions.GetNoPassword() != nil {
 		return nil, nil
 	}
@@ -379,10 +584,7 @@ func getCredentialOption(credentialOptions *v2.CredentialOptions) (*okta.UserCre
 		return nil, errors.New("unsupported credential options")
 	}
 
-	length := min(8, credentialOptions.GetRandomPassword().GetLength())
-	plaintextPassword, err := crypto.GenerateRandomPassword(&v2.CredentialOptions_RandomPassword{
-		Length: length,
-	})
+	plaintextPassword, err := o.generatePassword(ctx, credentialOptions.GetRandomPassword())
 	if err != nil {
 		return nil, err
 	}
@@ -394,34 +596,16 @@ func getCredentialOption(credentialOptions *v2.CredentialOptions) (*okta.UserCre
 	}, nil
 }
 
-func getUserProfile(accountInfo *v2.AccountInfo) (*okta.UserProfile, error) {
-	pMap := accountInfo.Profile.AsMap()
-	firstName, ok := pMap["first_name"]
-	if !ok {
-		return nil, fmt.Errorf("okta-connectorv2: missing first name in account info")
-	}
-
-	lastName, ok := pMap["last_name"]
-	if !ok {
-		return nil, fmt.Errorf("okta-connectorv2: missing last name in account info")
-	}
-
-	email, ok := pMap["email"]
-	if !ok {
-		return nil, fmt.Errorf("okta-connectorv2: missing email in account info")
-	}
-
-	login, ok := pMap["login"]
-	if !ok {
-		login = email
+// getUserProfile builds the profile of a new user from the account info, validated against the profile schema of
+// the user type. Only the fixed fields are used when the schema can't be read.
+func (o *Okta) getUserProfile(ctx context.Context, accountInfo *v2.AccountInfo, userType *okta.UserType) (*okta.UserProfile, error) {
+	schema, err := o.getUserSchema(ctx, userType)
+	if err != nil {
+		ctxzap.Extract(ctx).Warn("okta-connector: failed to get user profile schema, only using the fixed account creation fields", zap.Error(err))
+		schema = fixedUserSchema()
 	}
 
-	return &okta.UserProfile{
-		"firstName": firstName,
-		"lastName":  lastName,
-		"email":     email,
-		"login":     login,
-	}, nil
+	return userProfileFromSchema(accountInfo.Profile.AsMap(), schema)
 }
 
 func getAccountCreationQueryParams(accountInfo *v2.AccountInfo, credentialOptions *v2.CredentialOptions) (*query.Params, error) {
@@ -464,8 +648,12 @@ func (o *userResourceType) Get(ctx context.Context, resourceId *v2.ResourceId, p
 		return nil, nil, nil
 	}
 
-	user, respCtx, err := getUser(ctx, o.connector.client, resourceId.Resource)
+	user, respCtx, err := getUser(ctx, o.connector.client, resourceId.Resource, o.connector.syncIdentityProviders)
 	if err != nil {
+		// Deleted users are not found, which tells the caller the resource is gone.
+		if respCtx != nil && respCtx.OktaResponse.StatusCode == http.StatusNotFound {
+			return nil, nil, status.Errorf(codes.NotFound, "okta-connectorv2: user %s not found", resourceId.Resource)
+		}
 		return nil, nil, fmt.Errorf("okta-connectorv2: failed to find user: %w", err)
 	}
 
@@ -484,7 +672,7 @@ func (o *userResourceType) Get(ctx context.Context, resourceId *v2.ResourceId, p
 		return nil, annos, nil
 	}
 
-	resource, err := userResource(ctx, user, o.connector.skipSecondaryEmails)
+	resource, err := o.connector.userResource(ctx, user)
 	if err != nil {
 		return nil, annos, err
 	}
@@ -492,7 +680,7 @@ func (o *userResourceType) Get(ctx context.Context, resourceId *v2.ResourceId, p
 	return resource, annos, nil
 }
 
-func getUser(ctx context.Context, client *okta.Client, oktaUserID string) (*okta.User, *responseContext, error) {
+func getUser(ctx context.Context, client *okta.Client, oktaUserID string, includeCredentials bool) (*okta.User, *responseContext, error) {
 	reqUrl, err := url.Parse(usersUrl)
 	if err != nil {
 		return nil, nil, err
@@ -500,24 +688,24 @@ func getUser(ctx context.Context, client *okta.Client, oktaUserID string) (*okta
 
 	reqUrl = reqUrl.JoinPath(oktaUserID)
 
-	// Using okta-response="omitCredentials,omitCredentialsLinks,omitTransitioningToStatus" in the content type header omits
-	// the credentials, credentials links, and `transitioningToStatus` field from the response which applies performance optimization.
-	// https://developer.okta.com/docs/api/openapi/okta-management/management/tag/User/#tag/User/operation/listUsers!in=header&path=Content-Type&t=request
 	oktaUsers := &okta.User{}
 	rq := client.CloneRequestExecutor()
 	req, err := rq.
 		WithAccept(ContentType).
-		WithContentType(`application/json; okta-response="omitCredentials,omitCredentialsLinks,omitTransitioningToStatus"`).
+		WithContentType(userResponseContentType(includeCredentials)).
 		NewRequest(http.MethodGet, reqUrl.String(), nil)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// Need to set content type here because the response was still including the credentials when setting it with WithContentType above
-	req.Header.Set("Content-Type", `application/json; okta-response="omitCredentials,omitCredentialsLinks,omitTransitioningToStatus"`)
+	req.Header.Set("Content-Type", userResponseContentType(includeCredentials))
 
 	resp, err := rq.Do(ctx, req, &oktaUsers)
 	if err != nil {
+		if resp != nil {
+			return nil, &responseContext{OktaResponse: resp}, err
+		}
 		return nil, nil, err
 	}
 
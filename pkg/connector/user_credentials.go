@@ -0,0 +1,206 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/crypto"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/okta/okta-sdk-golang/v2/okta/query"
+	"go.uber.org/zap"
+)
+
+const (
+	apiPathPolicies = "/api/v1/policies"
+
+	policyTypePassword = "PASSWORD"
+	policyStatusActive = "ACTIVE"
+
+	// How a user is rotated to no password, either by emailing them a password reset link or by expiring their
+	// password so it has to be changed on the next sign in.
+	NoPasswordRotationResetEmail = "reset-email"
+	NoPasswordRotationExpire     = "expire"
+
+	// Character sets used for the password policy complexity requirements.
+	passwordLowerCase = "abcdefghijklmnopqrstuvwxyz"
+	passwordUpperCase = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	passwordNumbers   = "0123456789"
+	passwordSymbols   = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+)
+
+var errMissingPolicyPermissions = errors.New("okta-connector: missing permissions to read policies")
+
+func (o *userResourceType) RotateCapabilityDetails(ctx context.Context) (*v2.CredentialDetailsCredentialRotation, annotations.Annotations, error) {
+	return &v2.CredentialDetailsCredentialRotation{
+		SupportedCredentialOptions: []v2.CapabilityDetailCredentialOption{
+			v2.CapabilityDetailCredentialOption_CAPABILITY_DETAIL_CREDENTIAL_OPTION_NO_PASSWORD,
+			v2.CapabilityDetailCredentialOption_CAPABILITY_DETAIL_CREDENTIAL_OPTION_RANDOM_PASSWORD,
+		},
+		PreferredCredentialOption: v2.CapabilityDetailCredentialOption_CAPABILITY_DETAIL_CREDENTIAL_OPTION_NO_PASSWORD,
+	}, nil, nil
+}
+
+// Rotate sets a new random password on the user, or with no password sends a password reset email or expires the
+// password depending on the no-password rotation setting.
+func (o *userResourceType) Rotate(
+	ctx context.Context,
+	resourceId *v2.ResourceId,
+	credentialOptions *v2.CredentialOptions,
+) ([]*v2.PlaintextData, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	if resourceId.ResourceType != resourceTypeUser.Id {
+		return nil, nil, fmt.Errorf("okta-connector: can only rotate user credentials, got %s", resourceId.ResourceType)
+	}
+	userID := resourceId.Resource
+
+	if credentialOptions.GetNoPassword() != nil {
+		switch o.connector.noPasswordRotation {
+		case NoPasswordRotationExpire:
+			_, response, err := o.connector.client.User.ExpirePassword(ctx, userID)
+			if err != nil {
+				return nil, nil, fmt.Errorf("okta-connector: failed to expire user password: %w", handleOktaResponseError(response, err))
+			}
+		default:
+			_, response, err := o.connector.client.User.ResetPassword(ctx, userID, query.NewQueryParams(query.WithSendEmail(true)))
+			if err != nil {
+				return nil, nil, fmt.Errorf("okta-connector: failed to reset user password: %w", handleOktaResponseError(response, err))
+			}
+		}
+
+		l.Warn("User password has been rotated",
+			zap.String("user_id", userID),
+			zap.String("method", o.connector.noPasswordRotation),
+		)
+
+		return nil, nil, nil
+	}
+
+	if credentialOptions.GetRandomPassword() == nil {
+		return nil, nil, fmt.Errorf("okta-connector: unsupported credential options")
+	}
+
+	password, err := o.connector.generatePassword(ctx, credentialOptions.GetRandomPassword())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, response, err := o.connector.client.User.PartialUpdateUser(ctx, userID, okta.User{
+		Credentials: &okta.UserCredentials{
+			Password: &okta.PasswordCredential{
+				Value: password,
+			},
+		},
+	}, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connector: failed to set user password: %w", handleOktaResponseError(response, err))
+	}
+
+	l.Warn("User password has been rotated",
+		zap.String("user_id", userID),
+		zap.String("method", "random-password"),
+	)
+
+	return []*v2.PlaintextData{passwordPlaintextData(password)}, nil, nil
+}
+
+func passwordPlaintextData(password string) *v2.PlaintextData {
+	return &v2.PlaintextData{
+		Name:        "password",
+		Description: "The generated password of the user",
+		Schema:      "string",
+		Bytes:       []byte(password),
+	}
+}
+
+// generatePassword generates a random password of the requested length that also meets the complexity of every
+// active password policy of the org, since the policy that applies depends on the groups of the user.
+func (o *Okta) generatePassword(ctx context.Context, randomPassword *v2.CredentialOptions_RandomPassword) (string, error) {
+	complexity, err := getPasswordComplexity(ctx, o.client)
+	if err != nil {
+		// Reading policies needs the okta.policies.read scope, without it Okta still rejects passwords that don't
+		// meet the policy when they are set.
+		if !errors.Is(err, errMissingPolicyPermissions) {
+			return "", fmt.Errorf("okta-connector: failed to get password policy: %w", err)
+		}
+		ctxzap.Extract(ctx).Warn("okta-connector: missing permissions to read password policies, generating the password without them")
+	}
+
+	password, err := crypto.GenerateRandomPassword(passwordGenerationOptions(randomPassword, complexity))
+	if err != nil {
+		return "", fmt.Errorf("okta-connector: failed to generate password: %w", err)
+	}
+
+	return password, nil
+}
+
+// getPasswordComplexity returns the strictest complexity requirements of the active password policies.
+func getPasswordComplexity(ctx context.Context, client *okta.Client) (*PasswordComplexity, error) {
+	qp := query.NewQueryParams(query.WithType(policyTypePassword))
+
+	var policies []*PasswordPolicy
+	resp, err := doRequest(ctx, client, http.MethodGet, apiPathPolicies+qp.String(), nil, &policies)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusForbidden {
+			return nil, errMissingPolicyPermissions
+		}
+		return nil, handleOktaResponseError(resp, err)
+	}
+
+	complexity := &PasswordComplexity{}
+	for _, policy := range policies {
+		if policy.Status != policyStatusActive {
+			continue
+		}
+
+		c := policy.Settings.Password.Complexity
+		complexity.MinLength = max(complexity.MinLength, c.MinLength)
+		complexity.MinLowerCase = max(complexity.MinLowerCase, c.MinLowerCase)
+		complexity.MinUpperCase = max(complexity.MinUpperCase, c.MinUpperCase)
+		complexity.MinNumber = max(complexity.MinNumber, c.MinNumber)
+		complexity.MinSymbol = max(complexity.MinSymbol, c.MinSymbol)
+	}
+
+	return complexity, nil
+}
+
+// passwordGenerationOptions raises the requested length to the policy minimum and adds a constraint for every
+// character class the policy requires, keeping any constraints that were requested.
+func passwordGenerationOptions(requested *v2.CredentialOptions_RandomPassword, complexity *PasswordComplexity) *v2.CredentialOptions_RandomPassword {
+	length := requested.GetLength()
+	if complexity == nil {
+		return &v2.CredentialOptions_RandomPassword{
+			Length:      length,
+			Constraints: requested.GetConstraints(),
+		}
+	}
+
+	length = max(length, complexity.MinLength)
+	constraints := append([]*v2.PasswordConstraint{}, requested.GetConstraints()...)
+	for _, required := range []struct {
+		charSet  string
+		minCount int64
+	}{
+		{passwordLowerCase, complexity.MinLowerCase},
+		{passwordUpperCase, complexity.MinUpperCase},
+		{passwordNumbers, complexity.MinNumber},
+		{passwordSymbols, complexity.MinSymbol},
+	} {
+		if required.minCount <= 0 {
+			continue
+		}
+		constraints = append(constraints, &v2.PasswordConstraint{
+			CharSet:  required.charSet,
+			MinCount: uint32(required.minCount),
+		})
+	}
+
+	return &v2.CredentialOptions_RandomPassword{
+		Length:      length,
+		Constraints: constraints,
+	}
+}
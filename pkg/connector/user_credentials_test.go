@@ -0,0 +1,43 @@
+package connector
+
+import (
+	"strings"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_passwordGenerationOptions(t *testing.T) {
+	requested := &v2.CredentialOptions_RandomPassword{Length: 32}
+
+	options := passwordGenerationOptions(requested, nil)
+	require.Equal(t, int64(32), options.Length)
+	require.Empty(t, options.Constraints)
+
+	// Lengths above 8 are no longer capped, and short lengths are raised to the policy minimum.
+	options = passwordGenerationOptions(requested, &PasswordComplexity{MinLength: 12})
+	require.Equal(t, int64(32), options.Length)
+	options = passwordGenerationOptions(&v2.CredentialOptions_RandomPassword{Length: 8}, &PasswordComplexity{MinLength: 12})
+	require.Equal(t, int64(12), options.Length)
+
+	options = passwordGenerationOptions(&v2.CredentialOptions_RandomPassword{
+		Length:      16,
+		Constraints: []*v2.PasswordConstraint{{CharSet: "xyz", MinCount: 2}},
+	}, &PasswordComplexity{MinLength: 8, MinUpperCase: 1, MinNumber: 1, MinSymbol: 1})
+	require.Equal(t, int64(16), options.Length)
+	require.Equal(t, []*v2.PasswordConstraint{
+		{CharSet: "xyz", MinCount: 2},
+		{CharSet: passwordUpperCase, MinCount: 1},
+		{CharSet: passwordNumbers, MinCount: 1},
+		{CharSet: passwordSymbols, MinCount: 1},
+	}, options.Constraints)
+
+	password, err := crypto.GenerateRandomPassword(options)
+	require.NoError(t, err)
+	require.Len(t, password, 16)
+	require.True(t, strings.ContainsAny(password, passwordUpperCase))
+	require.True(t, strings.ContainsAny(password, passwordNumbers))
+	require.True(t, strings.ContainsAny(password, passwordSymbols))
+}
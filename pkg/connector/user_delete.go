@@ -0,0 +1,112 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"go.uber.org/zap"
+)
+
+// Delete deactivates the user if needed and then permanently deletes them, unless the connector is configured to
+// stop at deactivation. Users holding a standard admin role are never deleted or deactivated.
+func (o *userResourceType) Delete(ctx context.Context, resourceId *v2.ResourceId) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	if resourceId.ResourceType != resourceTypeUser.Id {
+		return nil, fmt.Errorf("okta-connector: can only delete users, got %s", resourceId.ResourceType)
+	}
+
+	userID := resourceId.Resource
+	user, response, err := getCurrentUser(ctx, o.connector.client, userID)
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			l.Warn("okta-connector: user is already deleted", zap.String("user_id", userID))
+			return nil, nil
+		}
+		return nil, fmt.Errorf("okta-connector: failed to get user: %w", err)
+	}
+
+	adminRole, err := userAdminRole(ctx, o.connector.client, userID)
+	if err != nil {
+		if errors.Is(err, errMissingRolePermissions) {
+			return nil, fmt.Errorf("okta-connector: refusing to delete user %s, their admin roles can't be checked without role permissions", userID)
+		}
+		return nil, fmt.Errorf("okta-connector: failed to list user roles: %w", err)
+	}
+	if adminRole != nil {
+		return nil, fmt.Errorf("okta-connector: refusing to delete user %s, who has the %s role", userID, adminRole.Label)
+	}
+
+	if user.Status != userStatusDeprovisioned {
+		response, err = o.connector.client.User.DeactivateUser(ctx, userID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("okta-connector: failed to deactivate user: %w", handleOktaResponseError(response, err))
+		}
+
+		l.Warn("User has been deactivated",
+			zap.String("user_id", userID),
+			zap.String("previous_status", user.Status),
+		)
+	}
+
+	if o.connector.deactivateOnlyOnDelete {
+		return nil, nil
+	}
+
+	// Deleting a deactivated user removes them permanently.
+	response, err = o.connector.client.User.DeactivateOrDeleteUser(ctx, userID, nil)
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			l.Warn("okta-connector: user is already deleted", zap.String("user_id", userID))
+			return nil, nil
+		}
+		return nil, fmt.Errorf("okta-connector: failed to delete user: %w", handleOktaResponseError(response, err))
+	}
+
+	l.Warn("User has been deleted",
+		zap.String("user_id", userID),
+		zap.String("Status", response.Status),
+	)
+
+	return nil, nil
+}
+
+// userAdminRole returns the standard admin role a user currently holds, directly or through a group, or nil if
+// they hold none. Roles are read past the response cache, since the answer decides whether a user may be deleted.
+func userAdminRole(ctx context.Context, client *okta.Client, userID string) (*okta.Role, error) {
+	reqUrl, err := url.JoinPath(usersUrl, userID, "roles")
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []*Roles
+	resp, err := doUncachedRequest(ctx, client, http.MethodGet, reqUrl, nil, &roles)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusForbidden {
+			return nil, errMissingRolePermissions
+		}
+		return nil, handleOktaResponseError(resp, err)
+	}
+
+	return standardAdminRole(roles), nil
+}
+
+// standardAdminRole returns the first active standard role in the roles, or nil if there is none.
+func standardAdminRole(roles []*Roles) *okta.Role {
+	for _, role := range roles {
+		if role.Status != roleStatusActive {
+			continue
+		}
+		if standardRole := standardRoleFromType(role.Type); standardRole != nil {
+			return standardRole
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package connector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_standardAdminRole(t *testing.T) {
+	require.Nil(t, standardAdminRole(nil))
+	require.Nil(t, standardAdminRole([]*Roles{
+		{Type: roleTypeCustom, Status: userStatusActive},
+		{Type: "SUPER_ADMIN", Status: "INACTIVE"},
+	}))
+
+	role := standardAdminRole([]*Roles{
+		{Type: roleTypeCustom, Status: userStatusActive},
+		{Type: "HELP_DESK_ADMIN", Status: userStatusActive, AssignmentType: roleAssignmentTypeGroup},
+	})
+	require.NotNil(t, role)
+	require.Equal(t, "HELP_DESK_ADMIN", role.Type)
+}
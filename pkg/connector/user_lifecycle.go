@@ -0,0 +1,267 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	config "github.com/conductorone/baton-sdk/pb/c1/config/v1"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/okta/okta-sdk-golang/v2/okta/query"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	actionActivateUser   = "activate_user"
+	actionDeactivateUser = "deactivate_user"
+	actionSuspendUser    = "suspend_user"
+	actionUnsuspendUser  = "unsuspend_user"
+	actionReactivateUser = "reactivate_user"
+	actionExpirePassword = "expire_password"
+
+	actionArgSendEmail            = "send_email"
+	actionArgReturnActivationLink = "return_activation_link"
+
+	actionResultActivationLink = "activation_link"
+)
+
+// userLifecycleTransition describes which statuses a lifecycle action applies to. Users in one of the done statuses
+// are already where the action would take them, any other status can't be changed by the action.
+type userLifecycleTransition struct {
+	verb        string
+	from        []string
+	done        []string
+	doneMessage string
+	// The status of the user after the action, empty when Okta decides it.
+	status string
+}
+
+var userLifecycleTransitions = map[string]userLifecycleTransition{
+	actionActivateUser: {
+		verb: "activate",
+		from: []string{userStatusStaged, userStatusDeprovisioned},
+		done: []string{
+			userStatusActive, userStatusProvisioned, userStatusSuspended, userStatusLockedOut,
+			userStatusPasswordExpired, userStatusRecovery,
+		},
+		doneMessage: "the user has already been activated",
+	},
+	actionDeactivateUser: {
+		verb: "deactivate",
+		from: []string{
+			userStatusStaged, userStatusProvisioned, userStatusActive, userStatusSuspended, userStatusLockedOut,
+			userStatusPasswordExpired, userStatusRecovery,
+		},
+		done:        []string{userStatusDeprovisioned},
+		doneMessage: "the user is already deactivated",
+		status:      userStatusDeprovisioned,
+	},
+	actionSuspendUser: {
+		verb:        "suspend",
+		from:        []string{userStatusActive},
+		done:        []string{userStatusSuspended},
+		doneMessage: "the user is already suspended",
+		status:      userStatusSuspended,
+	},
+	actionUnsuspendUser: {
+		verb: "unsuspend",
+		from: []string{userStatusSuspended},
+		done: []string{
+			userStatusStaged, userStatusProvisioned, userStatusActive, userStatusDeprovisioned, userStatusLockedOut,
+			userStatusPasswordExpired, userStatusRecovery,
+		},
+		doneMessage: "the user isn't suspended",
+		status:      userStatusActive,
+	},
+	actionReactivateUser: {
+		verb: "reactivate",
+		from: []string{userStatusProvisioned},
+		done: []string{
+			userStatusActive, userStatusSuspended, userStatusLockedOut, userStatusPasswordExpired, userStatusRecovery,
+		},
+		doneMessage: "the user has already completed activation",
+		status:      userStatusProvisioned,
+	},
+	actionExpirePassword: {
+		verb:        "expire the password of",
+		from:        []string{userStatusActive},
+		done:        []string{userStatusPasswordExpired},
+		doneMessage: "the password has already expired",
+		status:      userStatusPasswordExpired,
+	},
+}
+
+// planUserLifecycleAction checks whether a lifecycle action can change a user with the given status. It returns false
+// with a message when there is nothing to do, and an error when the action doesn't apply to the status.
+func planUserLifecycleAction(action string, status string) (bool, string, error) {
+	transition, ok := userLifecycleTransitions[action]
+	if !ok {
+		return false, "", fmt.Errorf("okta-connector: unknown lifecycle action %s", action)
+	}
+
+	if slices.Contains(transition.from, status) {
+		return true, "", nil
+	}
+
+	if slices.Contains(transition.done, status) {
+		return false, fmt.Sprintf("user is %s, %s", status, transition.doneMessage), nil
+	}
+
+	return false, "", fmt.Errorf("okta-connector: can't %s a %s user, only %s users", transition.verb, status, strings.Join(transition.from, ", "))
+}
+
+var (
+	sendEmailActionArg = &config.Field{
+		Name:        actionArgSendEmail,
+		DisplayName: "Send Email",
+		Description: "Send the activation email to the user",
+		Field:       &config.Field_BoolField{BoolField: &config.BoolField{}},
+	}
+
+	returnActivationLinkActionArg = &config.Field{
+		Name:        actionArgReturnActivationLink,
+		DisplayName: "Return Activation Link",
+		Description: "Return the activation link instead of emailing it to the user",
+		Field:       &config.Field_BoolField{BoolField: &config.BoolField{}},
+	}
+
+	activationLinkReturnType = &config.Field{
+		Name:        actionResultActivationLink,
+		DisplayName: "Activation Link",
+		Description: "The link the user completes activation with",
+		IsSecret:    true,
+		Field:       &config.Field_StringField{StringField: &config.StringField{}},
+	}
+)
+
+func userLifecycleActionSchemas() []*v2.BatonActionSchema {
+	return []*v2.BatonActionSchema{
+		{
+			Name:        actionActivateUser,
+			DisplayName: "Activate User",
+			Description: "Activates a staged or deactivated user",
+			Arguments:   []*config.Field{userIDActionArg, sendEmailActionArg, returnActivationLinkActionArg},
+			ReturnTypes: append(slices.Clone(userActionReturnTypes), activationLinkReturnType),
+		},
+		{
+			Name:        actionDeactivateUser,
+			DisplayName: "Deactivate User",
+			Description: "Deactivates a user, which signs them out and unassigns their apps",
+			Arguments:   []*config.Field{userIDActionArg},
+			ReturnTypes: userActionReturnTypes,
+		},
+		{
+			Name:        actionSuspendUser,
+			DisplayName: "Suspend User",
+			Description: "Suspends an active user, who can't sign in until they are unsuspended",
+			Arguments:   []*config.Field{userIDActionArg},
+			ReturnTypes: userActionReturnTypes,
+		},
+		{
+			Name:        actionUnsuspendUser,
+			DisplayName: "Unsuspend User",
+			Description: "Returns a suspended user to active",
+			Arguments:   []*config.Field{userIDActionArg},
+			ReturnTypes: userActionReturnTypes,
+		},
+		{
+			Name:        actionReactivateUser,
+			DisplayName: "Reactivate User",
+			Description: "Restarts the activation of a user that hasn't completed it",
+			Arguments:   []*config.Field{userIDActionArg, sendEmailActionArg, returnActivationLinkActionArg},
+			ReturnTypes: append(slices.Clone(userActionReturnTypes), activationLinkReturnType),
+		},
+		{
+			Name:        actionExpirePassword,
+			DisplayName: "Expire Password",
+			Description: "Expires the password of an active user, who has to change it on their next sign in",
+			Arguments:   []*config.Field{userIDActionArg},
+			ReturnTypes: userActionReturnTypes,
+		},
+	}
+}
+
+// userLifecycleActionHandler returns the handler of a lifecycle action. It gets the current status of the user and
+// only calls Okta when the action changes the user.
+func (o *Okta) userLifecycleActionHandler(action string) func(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	return func(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+		l := ctxzap.Extract(ctx)
+		userID, err := requiredStringArg(args, actionArgUserID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		user, _, err := getCurrentUser(ctx, o.client, userID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("okta-connector: failed to get user: %w", err)
+		}
+
+		changed, message, err := planUserLifecycleAction(action, user.Status)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !changed {
+			return actionResult(userID, false, user.Status, message, nil)
+		}
+
+		extra, err := o.changeUserLifecycle(ctx, action, userID, args)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		l.Warn("User lifecycle has been changed",
+			zap.String("user_id", userID),
+			zap.String("action", action),
+			zap.String("previous_status", user.Status),
+		)
+
+		return actionResult(userID, true, userLifecycleTransitions[action].status, "", extra)
+	}
+}
+
+// changeUserLifecycle calls the Okta lifecycle operation of an action, returning the activation link when one
+// was asked for.
+func (o *Okta) changeUserLifecycle(ctx context.Context, action string, userID string, args *structpb.Struct) (map[string]interface{}, error) {
+	var (
+		activationToken *okta.UserActivationToken
+		response        *okta.Response
+		err             error
+	)
+
+	// Okta only returns the activation link when it doesn't send the activation email.
+	returnActivationLink := boolArg(args, actionArgReturnActivationLink)
+	qp := query.NewQueryParams(query.WithSendEmail(boolArg(args, actionArgSendEmail) && !returnActivationLink))
+
+	switch action {
+	case actionActivateUser:
+		activationToken, response, err = o.client.User.ActivateUser(ctx, userID, qp)
+	case actionReactivateUser:
+		activationToken, response, err = o.client.User.ReactivateUser(ctx, userID, qp)
+	case actionDeactivateUser:
+		response, err = o.client.User.DeactivateUser(ctx, userID, nil)
+	case actionSuspendUser:
+		response, err = o.client.User.SuspendUser(ctx, userID)
+	case actionUnsuspendUser:
+		response, err = o.client.User.UnsuspendUser(ctx, userID)
+	case actionExpirePassword:
+		_, response, err = o.client.User.ExpirePassword(ctx, userID)
+	default:
+		return nil, fmt.Errorf("okta-connector: unknown lifecycle action %s", action)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("okta-connector: failed to %s user: %w", userLifecycleTransitions[action].verb, handleOktaResponseError(response, err))
+	}
+
+	if returnActivationLink && activationToken != nil && activationToken.ActivationUrl != "" {
+		return map[string]interface{}{
+			actionResultActivationLink: activationToken.ActivationUrl,
+		}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,44 @@
+package connector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_planUserLifecycleAction(t *testing.T) {
+	tests := []struct {
+		action  string
+		status  string
+		changed bool
+		message string
+		wantErr bool
+	}{
+		{action: actionActivateUser, status: userStatusStaged, changed: true},
+		{action: actionActivateUser, status: userStatusDeprovisioned, changed: true},
+		{action: actionActivateUser, status: userStatusActive, message: "user is ACTIVE, the user has already been activated"},
+		{action: actionDeactivateUser, status: userStatusSuspended, changed: true},
+		{action: actionDeactivateUser, status: userStatusDeprovisioned, message: "user is DEPROVISIONED, the user is already deactivated"},
+		{action: actionSuspendUser, status: userStatusActive, changed: true},
+		{action: actionSuspendUser, status: userStatusSuspended, message: "user is SUSPENDED, the user is already suspended"},
+		{action: actionSuspendUser, status: userStatusStaged, wantErr: true},
+		{action: actionUnsuspendUser, status: userStatusSuspended, changed: true},
+		{action: actionUnsuspendUser, status: userStatusActive, message: "user is ACTIVE, the user isn't suspended"},
+		{action: actionReactivateUser, status: userStatusProvisioned, changed: true},
+		{action: actionReactivateUser, status: userStatusStaged, wantErr: true},
+		{action: actionExpirePassword, status: userStatusActive, changed: true},
+		{action: actionExpirePassword, status: userStatusPasswordExpired, message: "user is PASSWORD_EXPIRED, the password has already expired"},
+		{action: actionExpirePassword, status: userStatusDeprovisioned, wantErr: true},
+		{action: "unknown", status: userStatusActive, wantErr: true},
+	}
+	for _, tt := range tests {
+		changed, message, err := planUserLifecycleAction(tt.action, tt.status)
+		if tt.wantErr {
+			require.Error(t, err, "%s/%s", tt.action, tt.status)
+			continue
+		}
+		require.NoError(t, err, "%s/%s", tt.action, tt.status)
+		require.Equal(t, tt.changed, changed, "%s/%s", tt.action, tt.status)
+		require.Equal(t, tt.message, message, "%s/%s", tt.action, tt.status)
+	}
+}
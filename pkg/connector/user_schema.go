@@ -0,0 +1,592 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultUserSchemaID = "default"
+
+	userSchemaTypeString  = "string"
+	userSchemaTypeBoolean = "boolean"
+	userSchemaTypeInteger = "integer"
+	userSchemaTypeNumber  = "number"
+	userSchemaTypeArray   = "array"
+
+	userSchemaMutabilityReadOnly = "READ_ONLY"
+
+	accountFieldAllowOutsideEmailDomains = "allow_outside_email_domains"
+)
+
+// The base profile attributes that have fixed account creation fields.
+var accountCreationProfileFields = map[string]string{
+	"firstName": "first_name",
+	"lastName":  "last_name",
+	"email":     "email",
+	"login":     "login",
+}
+
+// userSchemaAttribute is an attribute of a user profile schema, with whether the schema requires it.
+type userSchemaAttribute struct {
+	Name     string
+	Required bool
+	Custom   bool
+	*okta.UserSchemaAttribute
+}
+
+// userSchemaAttributes returns the writable attributes of a user schema, sorted by name.
+func userSchemaAttributes(schema *okta.UserSchema) []*userSchemaAttribute {
+	var rv []*userSchemaAttribute
+	if schema == nil || schema.Definitions == nil {
+		return rv
+	}
+
+	add := func(properties map[string]*okta.UserSchemaAttribute, required []string, custom bool) {
+		for name, attr := range properties {
+			if attr == nil || attr.Mutability == userSchemaMutabilityReadOnly {
+				continue
+			}
+			rv = append(rv, &userSchemaAttribute{
+				Name:                name,
+				Required:            slices.Contains(required, name) || (attr.Required != nil && *attr.Required),
+				Custom:              custom,
+				UserSchemaAttribute: attr,
+			})
+		}
+	}
+	if schema.Definitions.Base != nil {
+		add(schema.Definitions.Base.Properties, schema.Definitions.Base.Required, false)
+	}
+	if schema.Definitions.Custom != nil {
+		add(schema.Definitions.Custom.Properties, schema.Definitions.Custom.Required, true)
+	}
+
+	sort.Slice(rv, func(i, j int) bool {
+		return rv[i].Name < rv[j].Name
+	})
+
+	return rv
+}
+
+// userSchemaID returns the id of the profile schema of a user type, from the schema link of the user type.
+func userSchemaID(userType *okta.UserType) string {
+	if userType == nil {
+		return defaultUserSchemaID
+	}
+
+	links, ok := userType.Links.(map[string]interface{})
+	if !ok {
+		return defaultUserSchemaID
+	}
+	schema, ok := links["schema"].(map[string]interface{})
+	if !ok {
+		return defaultUserSchemaID
+	}
+	href, ok := schema["href"].(string)
+	if !ok || href == "" {
+		return defaultUserSchemaID
+	}
+
+	return path.Base(href)
+}
+
+// getUserSchema gets the profile schema of a user type, or the default schema when there is no user type.
+func (o *Okta) getUserSchema(ctx context.Context, userType *okta.UserType) (*okta.UserSchema, error) {
+	schema, resp, err := o.client.UserSchema.GetUserSchema(ctx, userSchemaID(userType))
+	if err != nil {
+		return nil, fmt.Errorf("okta-connector: failed to get user schema: %w", handleOktaResponseError(resp, err))
+	}
+
+	return schema, nil
+}
+
+// fixedUserSchema is a profile schema with only the attributes of the fixed account creation fields, used when the
+// profile schema can't be read.
+func fixedUserSchema() *okta.UserSchema {
+	properties := make(map[string]*okta.UserSchemaAttribute, len(accountCreationProfileFields))
+	required := make([]string, 0, len(accountCreationProfileFields))
+	for name := range accountCreationProfileFields {
+		properties[name] = &okta.UserSchemaAttribute{Type: userSchemaTypeString}
+		required = append(required, name)
+	}
+
+	return &okta.UserSchema{
+		Definitions: &okta.UserSchemaDefinitions{
+			Base: &okta.UserSchemaBase{
+				Properties: properties,
+				Required:   required,
+			},
+		},
+	}
+}
+
+// accountCreationSchemaTTL is how long the account creation schema is used for before the profile schemas are read
+// again.
+const accountCreationSchemaTTL = 5 * time.Minute
+
+// accountCreationSchemaCache holds the account creation schema, so that the profile schemas aren't read on every
+// metadata request.
+type accountCreationSchemaCache struct {
+	mu      sync.Mutex
+	schema  *v2.ConnectorAccountCreationSchema
+	builtAt time.Time
+}
+
+// accountCreationSchema returns a copy of the account creation schema, building it again when it is older than
+// accountCreationSchemaTTL. The fixed fields are still usable for account creation when the profile schema can't be
+// read.
+func (o *Okta) accountCreationSchema(ctx context.Context) *v2.ConnectorAccountCreationSchema {
+	c := &o.accountCreationSchemas
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.schema == nil || time.Since(c.builtAt) > accountCreationSchemaTTL {
+		schema, err := o.buildAccountCreationSchema(ctx)
+		if err != nil {
+			ctxzap.Extract(ctx).Warn("okta-connector: failed to get user profile schema, using the default account creation fields", zap.Error(err))
+			schema = &v2.ConnectorAccountCreationSchema{FieldMap: defaultAccountCreationFields()}
+		}
+		c.schema = schema
+		c.builtAt = time.Now()
+	}
+
+	return proto.Clone(c.schema).(*v2.ConnectorAccountCreationSchema)
+}
+
+// buildAccountCreationSchema builds the account creation schema from the profile schemas of the default user type
+// and every other user type.
+func (o *Okta) buildAccountCreationSchema(ctx context.Context) (*v2.ConnectorAccountCreationSchema, error) {
+	defaultSchema, err := o.getUserSchema(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The default schema is still used when the user types can't be listed.
+	userTypes, resp, err := o.client.UserType.ListUserTypes(ctx)
+	if err != nil {
+		ctxzap.Extract(ctx).Warn("okta-connector: failed to list user types, only using the default user schema",
+			zap.Error(handleOktaResponseError(resp, err)),
+		)
+	}
+
+	userTypeSchemas := make(map[string]*okta.UserSchema)
+	for _, userType := range userTypes {
+		if userType.Default != nil && *userType.Default {
+			continue
+		}
+		schema, err := o.getUserSchema(ctx, userType)
+		if err != nil {
+			return nil, err
+		}
+		userTypeSchemas[userType.Name] = schema
+	}
+
+	return &v2.ConnectorAccountCreationSchema{
+		FieldMap: accountCreationFields(defaultSchema, userTypeSchemas),
+	}, nil
+}
+
+// accountCreationFields adds a field for every attribute of the user profile schemas that doesn't have a fixed
+// field. Base attributes are only added when they are required, custom attributes are always added. Fields are
+// only required when the default schema requires them, the schemas of other user types are checked when the account
+// is created.
+func accountCreationFields(defaultSchema *okta.UserSchema, userTypeSchemas map[string]*okta.UserSchema) map[string]*v2.ConnectorAccountCreationSchema_Field {
+	fields := defaultAccountCreationFields()
+
+	attrs := make(map[string]*userSchemaAttribute)
+	inDefault := make(map[string]bool)
+	userTypeNames := make(map[string][]string)
+	addAttributes := func(schema *okta.UserSchema, userTypeName string) {
+		for _, attr := range userSchemaAttributes(schema) {
+			if _, ok := accountCreationProfileFields[attr.Name]; ok {
+				continue
+			}
+			if !attr.Custom && !attr.Required {
+				continue
+			}
+
+			if userTypeName == "" {
+				inDefault[attr.Name] = true
+			} else {
+				userTypeNames[attr.Name] = append(userTypeNames[attr.Name], userTypeName)
+			}
+
+			if _, ok := attrs[attr.Name]; ok {
+				continue
+			}
+			attrs[attr.Name] = &userSchemaAttribute{
+				Name:                attr.Name,
+				Required:            attr.Required && userTypeName == "",
+				Custom:              attr.Custom,
+				UserSchemaAttribute: attr.UserSchemaAttribute,
+			}
+		}
+	}
+
+	addAttributes(defaultSchema, "")
+	names := make([]string, 0, len(userTypeSchemas))
+	for name := range userTypeSchemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		addAttributes(userTypeSchemas[name], name)
+	}
+
+	// Required fields come first, in name order.
+	sorted := make([]*userSchemaAttribute, 0, len(attrs))
+	for _, attr := range attrs {
+		sorted = append(sorted, attr)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Required != sorted[j].Required {
+			return sorted[i].Required
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	order := int32(len(fields))
+	for _, attr := range sorted {
+		order++
+		field := accountCreationField(attr, order)
+		if !inDefault[attr.Name] {
+			field.Description = strings.TrimSpace(fmt.Sprintf("%s Only used for the %s user types.", field.Description, strings.Join(userTypeNames[attr.Name], ", ")))
+		}
+		fields[attr.Name] = field
+	}
+
+	return fields
+}
+
+// accountCreationField creates the account creation field of a schema attribute. Allowed values are listed in the
+// description, since account creation fields have no options.
+func accountCreationField(attr *userSchemaAttribute, order int32) *v2.ConnectorAccountCreationSchema_Field {
+	displayName := attr.Title
+	if displayName == "" {
+		displayName = attr.Name
+	}
+
+	description := attr.Description
+	if values := schemaEnumValues(attr.UserSchemaAttribute); len(values) > 0 {
+		description = strings.TrimSpace(fmt.Sprintf("%s One of: %s.", description, strings.Join(values, ", ")))
+	}
+
+	field := &v2.ConnectorAccountCreationSchema_Field{
+		DisplayName: displayName,
+		Required:    attr.Required,
+		Description: description,
+		Placeholder: displayName,
+		Order:       order,
+	}
+
+	switch attr.Type {
+	case userSchemaTypeBoolean:
+		field.Field = &v2.ConnectorAccountCreationSchema_Field_BoolField{
+			BoolField: &v2.ConnectorAccountCreationSchema_BoolField{},
+		}
+	case userSchemaTypeInteger:
+		field.Field = &v2.ConnectorAccountCreationSchema_Field_IntField{
+			IntField: &v2.ConnectorAccountCreationSchema_IntField{},
+		}
+	case userSchemaTypeArray:
+		field.Field = &v2.ConnectorAccountCreationSchema_Field_StringListField{
+			StringListField: &v2.ConnectorAccountCreationSchema_StringListField{},
+		}
+	default:
+		// Numbers are entered as strings and converted when the account is created.
+		field.Field = &v2.ConnectorAccountCreationSchema_Field_StringField{
+			StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+		}
+	}
+
+	return field
+}
+
+// schemaEnumValues returns the allowed values of an attribute, or of its items for arrays.
+func schemaEnumValues(attr *okta.UserSchemaAttribute) []string {
+	enum, oneOf := attr.Enum, attr.OneOf
+	if attr.Type == userSchemaTypeArray && attr.Items != nil {
+		enum, oneOf = attr.Items.Enum, attr.Items.OneOf
+	}
+
+	var values []string
+	for _, v := range enum {
+		values = append(values, fmt.Sprint(v))
+	}
+	for _, v := range oneOf {
+		if v == nil {
+			continue
+		}
+		value := fmt.Sprint(v.Const)
+		if !slices.Contains(values, value) {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// userProfileFromSchema builds the okta profile of a new user from the account info, validating the values
+// against the user schema and converting them to the attribute types. The fixed fields are read from their
+// account creation keys, any other attribute from its profile attribute name.
+func userProfileFromSchema(pMap map[string]interface{}, schema *okta.UserSchema) (*okta.UserProfile, error) {
+	profile := okta.UserProfile{}
+	var missing []string
+	for _, attr := range userSchemaAttributes(schema) {
+		key := attr.Name
+		if fieldKey, ok := accountCreationProfileFields[attr.Name]; ok {
+			key = fieldKey
+		}
+
+		value, ok := pMap[key]
+		if !ok || isEmptyProfileValue(value) {
+			// Without a login the email is used as the login.
+			if attr.Name == "login" && !isEmptyProfileValue(pMap["email"]) {
+				value = pMap["email"]
+			} else {
+				if attr.Required {
+					missing = append(missing, key)
+				}
+				continue
+			}
+		}
+
+		converted, err := convertSchemaValue(attr.UserSchemaAttribute, value)
+		if err != nil {
+			return nil, fmt.Errorf("okta-connector: invalid value for %s: %w", key, err)
+		}
+		profile[attr.Name] = converted
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("okta-connector: missing required profile attributes: %s", strings.Join(missing, ", "))
+	}
+
+	return &profile, nil
+}
+
+func isEmptyProfileValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	}
+
+	return false
+}
+
+// convertSchemaValue converts a submitted value to the type of a schema attribute and checks it against the
+// allowed values of the attribute.
+func convertSchemaValue(attr *okta.UserSchemaAttribute, value interface{}) (interface{}, error) {
+	if attr.Type == userSchemaTypeArray {
+		var items []interface{}
+		switch v := value.(type) {
+		case []interface{}:
+			items = v
+		case string:
+			for _, item := range strings.Split(v, ",") {
+				items = append(items, strings.TrimSpace(item))
+			}
+		default:
+			items = []interface{}{v}
+		}
+
+		itemType := userSchemaTypeString
+		if attr.Items != nil && attr.Items.Type != "" {
+			itemType = attr.Items.Type
+		}
+
+		rv := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			converted, err := convertSchemaScalar(itemType, item)
+			if err != nil {
+				return nil, err
+			}
+			rv = append(rv, converted)
+		}
+		if err := checkSchemaEnum(attr, rv...); err != nil {
+			return nil, err
+		}
+
+		return rv, nil
+	}
+
+	converted, err := convertSchemaScalar(attr.Type, value)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchemaEnum(attr, converted); err != nil {
+		return nil, err
+	}
+
+	return converted, nil
+}
+
+func convertSchemaScalar(schemaType string, value interface{}) (interface{}, error) {
+	switch schemaType {
+	case userSchemaTypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("expected a boolean, got %q", v)
+			}
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected a boolean, got %v", value)
+	case userSchemaTypeInteger:
+		switch v := value.(type) {
+		case float64:
+			if v != math.Trunc(v) {
+				return nil, fmt.Errorf("expected an integer, got %v", v)
+			}
+			return int64(v), nil
+		case int64:
+			return v, nil
+		case string:
+			i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("expected an integer, got %q", v)
+			}
+			return i, nil
+		}
+		return nil, fmt.Errorf("expected an integer, got %v", value)
+	case userSchemaTypeNumber:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int64:
+			return float64(v), nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("expected a number, got %q", v)
+			}
+			return f, nil
+		}
+		return nil, fmt.Errorf("expected a number, got %v", value)
+	default:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case bool, float64, int64:
+			return fmt.Sprint(v), nil
+		}
+		return nil, fmt.Errorf("expected a string, got %v", value)
+	}
+}
+
+func checkSchemaEnum(attr *okta.UserSchemaAttribute, values ...interface{}) error {
+	allowed := schemaEnumValues(attr)
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	for _, value := range values {
+		if !slices.Contains(allowed, fmt.Sprint(value)) {
+			return fmt.Errorf("%v is not one of %s", value, strings.Join(allowed, ", "))
+		}
+	}
+
+	return nil
+}
+
+// allowOutsideEmailDomainsField is the account creation field that overrides the CIAM email domain policy.
+func allowOutsideEmailDomainsField(order int32) *v2.ConnectorAccountCreationSchema_Field {
+	return &v2.ConnectorAccountCreationSchema_Field{
+		DisplayName: "Allow Outside Email Domains",
+		Required:    false,
+		Description: "Setting this to 'true' creates the user even if their email or login is outside the CIAM email domains. Such users are not synced.",
+		Field: &v2.ConnectorAccountCreationSchema_Field_BoolField{
+			BoolField: &v2.ConnectorAccountCreationSchema_BoolField{},
+		},
+		Placeholder: "True/False",
+		Order:       order,
+	}
+}
+
+// defaultAccountCreationFields returns the fixed account creation fields.
+func defaultAccountCreationFields() map[string]*v2.ConnectorAccountCreationSchema_Field {
+	return map[string]*v2.ConnectorAccountCreationSchema_Field{
+		"first_name": {
+			DisplayName: "First Name",
+			Required:    true,
+			Description: "This first name will be used for the user.",
+			Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
+				StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+			},
+			Placeholder: "First name",
+			Order:       1,
+		},
+		"last_name": {
+			DisplayName: "Last Name",
+			Required:    true,
+			Description: "This last name will be used for the user.",
+			Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
+				StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+			},
+			Placeholder: "Last name",
+			Order:       2,
+		},
+		"email": {
+			DisplayName: "Email",
+			Required:    true,
+			Description: "This will be the email of the user. If login is unset this is also the login.",
+			Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
+				StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+			},
+			Placeholder: "Email",
+			Order:       3,
+		},
+		"login": {
+			DisplayName: "Login",
+			Required:    false,
+			Description: "This login will be used as the login for the user. Email will be used if login is not present.",
+			Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
+				StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+			},
+			Placeholder: "Login",
+			Order:       4,
+		},
+		"password_change_on_login_required": {
+			DisplayName: "Password Change Required on Login",
+			Required:    false,
+			Description: "When creating accounts with a random password setting this to 'true' will require the user to change their password on first login.",
+			Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
+				StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+			},
+			Placeholder: "True/False",
+			Order:       5,
+		},
+		"user_type": {
+			DisplayName: "User Type",
+			Required:    false,
+			Description: "The name or id of the Okta user type of the user. The default user type is used if this is unset.",
+			Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
+				StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+			},
+			Placeholder: "User type",
+			Order:       6,
+		},
+	}
+}
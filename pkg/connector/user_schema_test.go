@@ -0,0 +1,201 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func testUserSchema() *okta.UserSchema {
+	return &okta.UserSchema{
+		Definitions: &okta.UserSchemaDefinitions{
+			Base: &okta.UserSchemaBase{
+				Properties: map[string]*okta.UserSchemaAttribute{
+					"firstName":   {Title: "First name", Type: "string"},
+					"lastName":    {Title: "Last name", Type: "string"},
+					"email":       {Title: "Primary email", Type: "string"},
+					"login":       {Title: "Username", Type: "string"},
+					"mobilePhone": {Title: "Mobile phone", Type: "string"},
+				},
+				Required: []string{"firstName", "lastName", "email", "login"},
+			},
+			Custom: &okta.UserSchemaPublic{
+				Properties: map[string]*okta.UserSchemaAttribute{
+					"customerId": {Title: "Customer ID", Type: "string"},
+					"region":     {Title: "Region", Type: "string", Enum: []interface{}{"eu", "us"}},
+					"marketingConsent": {
+						Title: "Marketing consent",
+						Type:  "boolean",
+					},
+					"loyaltyPoints": {Title: "Loyalty points", Type: "integer"},
+					"interests": {
+						Title: "Interests",
+						Type:  "array",
+						Items: &okta.UserSchemaAttributeItems{
+							Type: "string",
+							OneOf: []*okta.UserSchemaAttributeEnum{
+								{Const: "sports", Title: "Sports"},
+								{Const: "travel", Title: "Travel"},
+							},
+						},
+					},
+					"internalScore": {Title: "Internal score", Type: "number", Mutability: "READ_ONLY"},
+				},
+				Required: []string{"customerId", "region"},
+			},
+		},
+	}
+}
+
+func Test_accountCreationFields(t *testing.T) {
+	partnerSchema := testUserSchema()
+	partnerSchema.Definitions.Custom.Properties["partnerCode"] = &okta.UserSchemaAttribute{Title: "Partner code", Type: "string"}
+	partnerSchema.Definitions.Custom.Required = append(partnerSchema.Definitions.Custom.Required, "partnerCode")
+
+	fields := accountCreationFields(testUserSchema(), map[string]*okta.UserSchema{"partner": partnerSchema})
+
+	for key := range defaultAccountCreationFields() {
+		require.Contains(t, fields, key)
+	}
+	require.NotContains(t, fields, "firstName")
+	require.NotContains(t, fields, "mobilePhone")
+	require.NotContains(t, fields, "internalScore")
+
+	require.True(t, fields["customerId"].Required)
+	require.Equal(t, int32(7), fields["customerId"].Order)
+	require.Equal(t, int32(8), fields["region"].Order)
+	require.Equal(t, "One of: eu, us.", fields["region"].Description)
+
+	require.False(t, fields["marketingConsent"].Required)
+	require.IsType(t, &v2.ConnectorAccountCreationSchema_Field_BoolField{}, fields["marketingConsent"].Field)
+	require.IsType(t, &v2.ConnectorAccountCreationSchema_Field_IntField{}, fields["loyaltyPoints"].Field)
+	require.IsType(t, &v2.ConnectorAccountCreationSchema_Field_StringListField{}, fields["interests"].Field)
+	require.Equal(t, "One of: sports, travel.", fields["interests"].Description)
+
+	require.False(t, fields["partnerCode"].Required)
+	require.Equal(t, "Only used for the partner user types.", fields["partnerCode"].Description)
+}
+
+func Test_userSchemaID(t *testing.T) {
+	require.Equal(t, "default", userSchemaID(nil))
+	require.Equal(t, "default", userSchemaID(&okta.UserType{}))
+	require.Equal(t, "osc1", userSchemaID(&okta.UserType{
+		Links: map[string]interface{}{
+			"schema": map[string]interface{}{
+				"href": "https://example.okta.com/api/v1/meta/schemas/user/osc1",
+			},
+		},
+	}))
+}
+
+func Test_userProfileFromSchema(t *testing.T) {
+	profile, err := userProfileFromSchema(map[string]interface{}{
+		"first_name":       "Jane",
+		"last_name":        "Doe",
+		"email":            "jane@example.com",
+		"customerId":       "c-1",
+		"region":           "eu",
+		"marketingConsent": "true",
+		"loyaltyPoints":    float64(10),
+		"interests":        "sports, travel",
+		"user_type":        "customer",
+	}, testUserSchema())
+	require.NoError(t, err)
+	require.Equal(t, okta.UserProfile{
+		"firstName":        "Jane",
+		"lastName":         "Doe",
+		"email":            "jane@example.com",
+		"login":            "jane@example.com",
+		"customerId":       "c-1",
+		"region":           "eu",
+		"marketingConsent": true,
+		"loyaltyPoints":    int64(10),
+		"interests":        []interface{}{"sports", "travel"},
+	}, *profile)
+
+	_, err = userProfileFromSchema(map[string]interface{}{
+		"first_name": "Jane",
+		"email":      "jane@example.com",
+	}, testUserSchema())
+	require.EqualError(t, err, "okta-connector: missing required profile attributes: customerId, last_name, region")
+
+	_, err = userProfileFromSchema(map[string]interface{}{
+		"first_name": "Jane",
+		"last_name":  "Doe",
+		"email":      "jane@example.com",
+		"customerId": "c-1",
+		"region":     "apac",
+	}, testUserSchema())
+	require.EqualError(t, err, "okta-connector: invalid value for region: apac is not one of eu, us")
+}
+
+func Test_convertSchemaValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		attr    *okta.UserSchemaAttribute
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"bool", &okta.UserSchemaAttribute{Type: "boolean"}, true, true, false},
+		{"bool string", &okta.UserSchemaAttribute{Type: "boolean"}, "false", false, false},
+		{"bool invalid", &okta.UserSchemaAttribute{Type: "boolean"}, "yes please", nil, true},
+		{"integer string", &okta.UserSchemaAttribute{Type: "integer"}, "42", int64(42), false},
+		{"integer fraction", &okta.UserSchemaAttribute{Type: "integer"}, 4.2, nil, true},
+		{"number string", &okta.UserSchemaAttribute{Type: "number"}, "4.5", 4.5, false},
+		{"string from number", &okta.UserSchemaAttribute{Type: "string"}, float64(7), "7", false},
+		{"array list", &okta.UserSchemaAttribute{Type: "array", Items: &okta.UserSchemaAttributeItems{Type: "integer"}}, []interface{}{"1", float64(2)}, []interface{}{int64(1), int64(2)}, false},
+		{"enum", &okta.UserSchemaAttribute{Type: "string", OneOf: []*okta.UserSchemaAttributeEnum{{Const: "a"}}}, "b", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertSchemaValue(tt.attr, tt.value)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_getUserProfileWithoutSchema(t *testing.T) {
+	schemaReads := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/meta/schemas/user/default", func(w http.ResponseWriter, r *http.Request) {
+		schemaReads++
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errorCode": "E0000006", "errorSummary": "You do not have permission to perform the requested action"}`))
+	})
+	o := &Okta{client: newTestClient(t, mux, okta.WithCache(false))}
+	ctx := context.Background()
+
+	accountProfile, err := structpb.NewStruct(map[string]interface{}{
+		"first_name": "Jane",
+		"last_name":  "Doe",
+		"email":      "jane@example.com",
+		"customerId": "c-1",
+	})
+	require.NoError(t, err)
+
+	profile, err := o.getUserProfile(ctx, &v2.AccountInfo{Profile: accountProfile}, nil)
+	require.NoError(t, err)
+	require.Equal(t, okta.UserProfile{
+		"firstName": "Jane",
+		"lastName":  "Doe",
+		"email":     "jane@example.com",
+		"login":     "jane@example.com",
+	}, *profile)
+
+	for range 2 {
+		schema := o.accountCreationSchema(ctx)
+		require.Equal(t, defaultAccountCreationFields(), schema.FieldMap)
+	}
+	require.Equal(t, 2, schemaReads)
+}
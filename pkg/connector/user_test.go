@@ -1,6 +1,17 @@
 package connector
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
 
 func Test_shouldIncludeUserByEmails(t *testing.T) {
 	type args struct {
@@ -101,3 +112,91 @@ func Test_shouldIncludeUserByEmails(t *testing.T) {
 		})
 	}
 }
+
+func Test_userResource(t *testing.T) {
+	user := &okta.User{
+		Id:     "00u1",
+		Status: userStatusActive,
+		Type:   &okta.UserType{Id: "oty1"},
+		Profile: &okta.UserProfile{
+			"firstName": "Alice",
+			"lastName":  "Smith",
+			"email":     "alice@foo.com",
+			"login":     "alice@foo.com",
+		},
+		Credentials: &okta.UserCredentials{
+			Provider: &okta.AuthenticationProvider{Name: "Google", Type: "SOCIAL"},
+		},
+	}
+	userType := &okta.UserType{Id: "oty1", Name: "customer"}
+
+	r, err := userResource(context.Background(), user, false, userType, v2.UserTrait_ACCOUNT_TYPE_HUMAN)
+	require.NoError(t, err)
+
+	userTrait, err := resource.GetUserTrait(r)
+	require.NoError(t, err)
+	require.Equal(t, v2.UserTrait_ACCOUNT_TYPE_HUMAN, userTrait.GetAccountType())
+
+	profile := userTrait.GetProfile().AsMap()
+	require.Equal(t, "customer", profile["c1_okta_user_type"])
+	require.Equal(t, "oty1", profile["c1_okta_user_type_id"])
+	require.Equal(t, "SOCIAL", profile["c1_okta_credential_provider_type"])
+	require.Equal(t, "Google", profile["c1_okta_credential_provider_name"])
+}
+
+func Test_isLoginAlreadyExistsError(t *testing.T) {
+	loginTaken := &okta.Error{
+		ErrorCode:    "E0000001",
+		ErrorSummary: "Api validation failed: login",
+		ErrorCauses: []map[string]interface{}{
+			{"errorSummary": "login: An object with this field already exists in the current organization"},
+		},
+	}
+	require.True(t, isLoginAlreadyExistsError(loginTaken))
+	require.True(t, isLoginAlreadyExistsError(fmt.Errorf("wrapped: %w", loginTaken)))
+
+	require.False(t, isLoginAlreadyExistsError(&okta.Error{
+		ErrorCode: "E0000001",
+		ErrorCauses: []map[string]interface{}{
+			{"errorSummary": "email: Does not match required pattern"},
+		},
+	}))
+	require.False(t, isLoginAlreadyExistsError(&okta.Error{ErrorCode: "E0000090"}))
+	require.False(t, isLoginAlreadyExistsError(errors.New("okta-connector: failed")))
+}
+
+func Test_emailDomainPolicyViolation(t *testing.T) {
+	filters := lowerEmailDomains([]string{"Example.com"})
+
+	require.Empty(t, emailDomainPolicyViolation(&okta.UserProfile{
+		"email": "jane@example.com",
+		"login": "jane@EXAMPLE.com",
+	}, filters))
+	require.Empty(t, emailDomainPolicyViolation(&okta.UserProfile{
+		"email": "jane@example.com",
+		"login": "jane",
+	}, filters))
+	require.Equal(t, "email jane@other.com", emailDomainPolicyViolation(&okta.UserProfile{
+		"email": "jane@other.com",
+		"login": "jane@example.com",
+	}, filters))
+	require.Equal(t, "login jane@other.com", emailDomainPolicyViolation(&okta.UserProfile{
+		"email": "jane@example.com",
+		"login": "jane@other.com",
+	}, filters))
+}
+
+func Test_checkEmailDomainPolicy(t *testing.T) {
+	r := &userResourceType{emailFilters: []string{"example.com"}}
+	userProfile := &okta.UserProfile{"email": "jane@other.com", "login": "jane@other.com"}
+
+	accountInfo := &v2.AccountInfo{Profile: &structpb.Struct{}}
+	err := r.checkEmailDomainPolicy(context.Background(), accountInfo, userProfile)
+	require.EqualError(t, err, "okta-connector: email jane@other.com is outside the CIAM email domains example.com, the user would not be synced. Set allow_outside_email_domains to create them anyway")
+
+	profile, err := structpb.NewStruct(map[string]interface{}{accountFieldAllowOutsideEmailDomains: "true"})
+	require.NoError(t, err)
+	require.NoError(t, r.checkEmailDomainPolicy(context.Background(), &v2.AccountInfo{Profile: profile}, userProfile))
+
+	require.NoError(t, (&userResourceType{}).checkEmailDomainPolicy(context.Background(), accountInfo, userProfile))
+}
@@ -0,0 +1,259 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	sdkResource "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"go.uber.org/zap"
+)
+
+// Account type rules can match on the user type name with this attribute, any other attribute is looked up in
+// the user profile.
+const accountTypeRuleUserTypeAttribute = "type"
+
+type userTypeResourceType struct {
+	resourceType *v2.ResourceType
+	connector    *Okta
+}
+
+func (o *userTypeResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return o.resourceType
+}
+
+// User types aren't paginated by Okta, there can be at most 10 of them.
+func (o *userTypeResourceType) List(
+	ctx context.Context,
+	resourceID *v2.ResourceId,
+	token *pagination.Token,
+) ([]*v2.Resource, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	userTypes, resp, err := o.connector.client.UserType.ListUserTypes(ctx)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusForbidden {
+			l.Warn("okta-connectorv2: missing permissions to read user types")
+			return nil, "", nil, nil
+		}
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to list user types: %w", handleOktaResponseError(resp, err))
+	}
+
+	_, annos, err := parseResp(resp)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	var rv []*v2.Resource
+	for _, userType := range userTypes {
+		resource, err := userTypeResource(ctx, userType)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, resource)
+	}
+
+	return rv, "", annos, nil
+}
+
+func (o *userTypeResourceType) Entitlements(
+	_ context.Context,
+	_ *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func (o *userTypeResourceType) Grants(
+	_ context.Context,
+	_ *v2.Resource,
+	_ *pagination.Token,
+) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func (o *userTypeResourceType) Get(ctx context.Context, resourceId *v2.ResourceId, parentResourceId *v2.ResourceId) (*v2.Resource, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+	l.Debug("getting user type", zap.String("user_type_id", resourceId.Resource))
+
+	userType, resp, err := o.connector.client.UserType.GetUserType(ctx, resourceId.Resource)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connectorv2: failed to find user type: %w", handleOktaResponseError(resp, err))
+	}
+
+	_, annos, err := parseResp(resp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("okta-connectorv2: failed to parse response: %w", err)
+	}
+
+	resource, err := userTypeResource(ctx, userType)
+	if err != nil {
+		return nil, annos, err
+	}
+
+	return resource, annos, nil
+}
+
+// userTypeCacheTTL is how long the listed user types are used for before they are listed again.
+const userTypeCacheTTL = 5 * time.Minute
+
+// userTypeCache holds the user types of the org, so that they are listed once instead of read once per user.
+type userTypeCache struct {
+	mu        sync.Mutex
+	userTypes map[string]*okta.UserType
+	listedAt  time.Time
+}
+
+// get returns the user type with the given id, listing the user types when they haven't been listed within
+// userTypeCacheTTL. Missing permissions to read user types are logged and cached as no user types.
+func (c *userTypeCache) get(ctx context.Context, client *okta.Client, id string) (*okta.UserType, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.userTypes == nil || time.Since(c.listedAt) > userTypeCacheTTL {
+		userTypes, resp, err := client.UserType.ListUserTypes(ctx)
+		if err != nil {
+			if resp == nil || resp.StatusCode != http.StatusForbidden {
+				return nil, fmt.Errorf("okta-connectorv2: failed to list user types: %w", handleOktaResponseError(resp, err))
+			}
+			ctxzap.Extract(ctx).Warn("okta-connectorv2: missing permissions to read user types, syncing users without them")
+		}
+
+		c.userTypes = make(map[string]*okta.UserType, len(userTypes))
+		for _, userType := range userTypes {
+			c.userTypes[userType.Id] = userType
+		}
+		c.listedAt = time.Now()
+	}
+
+	return c.userTypes[id], nil
+}
+
+// getUserType returns nil if the user has no type, which Okta only does for users created before user types existed,
+// or if the user types can't be read.
+func (o *Okta) getUserType(ctx context.Context, user *okta.User) (*okta.UserType, error) {
+	if user.Type == nil || user.Type.Id == "" {
+		return nil, nil
+	}
+
+	return o.userTypes.get(ctx, o.client, user.Type.Id)
+}
+
+// findUserType looks up a user type by name or id.
+func (o *Okta) findUserType(ctx context.Context, nameOrID string) (*okta.UserType, error) {
+	userTypes, resp, err := o.client.UserType.ListUserTypes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("okta-connector: failed to list user types: %w", handleOktaResponseError(resp, err))
+	}
+
+	for _, userType := range userTypes {
+		if userType.Id == nameOrID || userType.Name == nameOrID {
+			return userType, nil
+		}
+	}
+
+	return nil, fmt.Errorf("okta-connector: user type %s not found", nameOrID)
+}
+
+// Create a new connector resource for an okta user type.
+func userTypeResource(ctx context.Context, userType *okta.UserType) (*v2.Resource, error) {
+	displayName := userType.DisplayName
+	if displayName == "" {
+		displayName = userType.Name
+	}
+
+	return sdkResource.NewResource(
+		displayName,
+		resourceTypeUserType,
+		userType.Id,
+		sdkResource.WithDescription(userType.Description),
+		sdkResource.WithAnnotation(&v2.V1Identifier{
+			Id: fmtResourceIdV1(userType.Id),
+		}),
+	)
+}
+
+func userTypeBuilder(connector *Okta) *userTypeResourceType {
+	return &userTypeResourceType{
+		resourceType: resourceTypeUserType,
+		connector:    connector,
+	}
+}
+
+// accountTypeRule sets the account type of users whose user type name or profile attribute matches the value.
+type accountTypeRule struct {
+	Attribute   string
+	Value       string
+	AccountType v2.UserTrait_AccountType
+}
+
+// parseAccountTypeRules parses rules in the form <attribute>=<value>:<human|service|system>,
+// e.g. type=serviceAccount:service or employeeType=Bot:system.
+func parseAccountTypeRules(rules []string) ([]*accountTypeRule, error) {
+	accountTypes := map[string]v2.UserTrait_AccountType{
+		"human":   v2.UserTrait_ACCOUNT_TYPE_HUMAN,
+		"service": v2.UserTrait_ACCOUNT_TYPE_SERVICE,
+		"system":  v2.UserTrait_ACCOUNT_TYPE_SYSTEM,
+	}
+
+	rv := make([]*accountTypeRule, 0, len(rules))
+	for _, rule := range rules {
+		// Values may contain a colon, so the account type is whatever comes after the last one.
+		i := strings.LastIndex(rule, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("okta-connector: invalid account type rule %q, expected <attribute>=<value>:<human|service|system>", rule)
+		}
+		match, accountTypeName := rule[:i], rule[i+1:]
+
+		attribute, value, ok := strings.Cut(match, "=")
+		if !ok || attribute == "" {
+			return nil, fmt.Errorf("okta-connector: invalid account type rule %q, expected <attribute>=<value>:<human|service|system>", rule)
+		}
+
+		accountType, ok := accountTypes[strings.ToLower(strings.TrimSpace(accountTypeName))]
+		if !ok {
+			return nil, fmt.Errorf("okta-connector: invalid account type %q in rule %q, expected human, service or system", accountTypeName, rule)
+		}
+
+		rv = append(rv, &accountTypeRule{
+			Attribute:   strings.TrimSpace(attribute),
+			Value:       strings.TrimSpace(value),
+			AccountType: accountType,
+		})
+	}
+
+	return rv, nil
+}
+
+// userAccountType returns the account type of the first matching rule, or human if no rule matches.
+func userAccountType(rules []*accountTypeRule, userType *okta.UserType, profile okta.UserProfile) v2.UserTrait_AccountType {
+	for _, rule := range rules {
+		var value string
+		if rule.Attribute == accountTypeRuleUserTypeAttribute {
+			if userType == nil {
+				continue
+			}
+			value = userType.Name
+		} else {
+			v, ok := profile[rule.Attribute]
+			if !ok || v == nil {
+				continue
+			}
+			value = fmt.Sprint(v)
+		}
+
+		if strings.EqualFold(value, rule.Value) {
+			return rule.AccountType
+		}
+	}
+
+	return v2.UserTrait_ACCOUNT_TYPE_HUMAN
+}
@@ -0,0 +1,85 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseAccountTypeRules(t *testing.T) {
+	rules, err := parseAccountTypeRules([]string{
+		"type=serviceAccount:service",
+		"employeeType = Bot : system",
+		"nickName=a:b:human",
+	})
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	require.Equal(t, &accountTypeRule{Attribute: "type", Value: "serviceAccount", AccountType: v2.UserTrait_ACCOUNT_TYPE_SERVICE}, rules[0])
+	require.Equal(t, &accountTypeRule{Attribute: "employeeType", Value: "Bot", AccountType: v2.UserTrait_ACCOUNT_TYPE_SYSTEM}, rules[1])
+	require.Equal(t, &accountTypeRule{Attribute: "nickName", Value: "a:b", AccountType: v2.UserTrait_ACCOUNT_TYPE_HUMAN}, rules[2])
+
+	for _, rule := range []string{"type=serviceAccount", "serviceAccount:service", "type=serviceAccount:robot", "=x:human"} {
+		_, err := parseAccountTypeRules([]string{rule})
+		require.Error(t, err, rule)
+	}
+}
+
+func Test_userAccountType(t *testing.T) {
+	rules, err := parseAccountTypeRules([]string{
+		"type=serviceAccount:service",
+		"employeeType=bot:system",
+		"employeeType=Employee:human",
+	})
+	require.NoError(t, err)
+
+	serviceType := &okta.UserType{Id: "oty1", Name: "serviceAccount"}
+	defaultType := &okta.UserType{Id: "oty2", Name: "user"}
+
+	require.Equal(t, v2.UserTrait_ACCOUNT_TYPE_SERVICE, userAccountType(rules, serviceType, okta.UserProfile{"employeeType": "Employee"}))
+	require.Equal(t, v2.UserTrait_ACCOUNT_TYPE_SYSTEM, userAccountType(rules, defaultType, okta.UserProfile{"employeeType": "Bot"}))
+	require.Equal(t, v2.UserTrait_ACCOUNT_TYPE_HUMAN, userAccountType(rules, nil, okta.UserProfile{"employeeType": "Employee"}))
+	require.Equal(t, v2.UserTrait_ACCOUNT_TYPE_HUMAN, userAccountType(rules, defaultType, okta.UserProfile{}))
+	require.Equal(t, v2.UserTrait_ACCOUNT_TYPE_HUMAN, userAccountType(nil, serviceType, okta.UserProfile{}))
+}
+
+func Test_getUserType(t *testing.T) {
+	listed := 0
+	status := http.StatusOK
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/meta/types/user", func(w http.ResponseWriter, r *http.Request) {
+		listed++
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"errorCode": "E0000006", "errorSummary": "You do not have permission to perform the requested action"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id": "oty1", "name": "user"}, {"id": "oty2", "name": "serviceAccount"}]`))
+	})
+	ctx := context.Background()
+
+	t.Run("listed once", func(t *testing.T) {
+		o := &Okta{client: newTestClient(t, mux, okta.WithCache(false))}
+		for _, id := range []string{"oty1", "oty2", "oty1"} {
+			userType, err := o.getUserType(ctx, &okta.User{Type: &okta.UserType{Id: id}})
+			require.NoError(t, err)
+			require.Equal(t, id, userType.Id)
+		}
+		require.Equal(t, 1, listed)
+	})
+
+	t.Run("missing permissions", func(t *testing.T) {
+		listed = 0
+		status = http.StatusForbidden
+		o := &Okta{client: newTestClient(t, mux, okta.WithCache(false))}
+		for range 2 {
+			userType, err := o.getUserType(ctx, &okta.User{Type: &okta.UserType{Id: "oty1"}})
+			require.NoError(t, err)
+			require.Nil(t, userType)
+		}
+		require.Equal(t, 1, listed)
+	})
+}